@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+)
+
+// APIKeyHeader is the request header carrying a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by a static key in the X-API-Key header.
+// Keys are looked up by their SHA-256 digest, so comparing them does not
+// leak how much of a guessed key was right.
+type APIKeys struct {
+	subjects map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys takes the accepted keys mapped to the subject each one
+// authenticates as.
+func NewAPIKeys(keys map[string]string) *APIKeys {
+	subjects := make(map[[sha256.Size]byte]string, len(keys))
+	for key, subject := range keys {
+		subjects[sha256.Sum256([]byte(key))] = subject
+	}
+	return &APIKeys{subjects: subjects}
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	subject, ok := a.subjects[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Subject: subject, Method: "api_key"}, nil
+}
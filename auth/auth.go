@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"poll/configs"
+	"slices"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Roles principals may be granted. Principals granted none are voters.
+const (
+	RoleAdmin  = "admin"
+	RoleVoter  = "voter"
+	RoleViewer = "viewer"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Roles   []string
+	// Method names how the principal authenticated: "api_key" or "jwt".
+	Method string
+}
+
+// Authenticator checks the credentials a request carries. It returns a nil
+// principal if the request has none of the kind it checks, and an error
+// wrapping ErrUnauthenticated if they are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// HasRole reports whether the principal was granted the role, counting
+// principals granted no roles at all as voters.
+func (p *Principal) HasRole(role string) bool {
+	if len(p.Roles) == 0 {
+		return role == RoleVoter
+	}
+	return slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal the request was authenticated as, if
+// any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+type anonymousRoleKey struct{}
+
+// WithAnonymousRole marks the request as coming without credentials to a
+// server that authenticates, which grants such callers the role.
+func WithAnonymousRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, anonymousRoleKey{}, role)
+}
+
+// AnonymousRole returns the role of the caller of a request marked by
+// WithAnonymousRole. Requests without credentials that are not marked come
+// from the service itself or from a server that does not authenticate.
+func AnonymousRole(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(anonymousRoleKey{}).(string)
+	return role, ok
+}
+
+// New returns the authenticators enabled in the config, API keys first. It
+// returns none if no credentials are configured.
+func New(cfg configs.AuthConfig) ([]Authenticator, error) {
+	switch cfg.AnonymousRole {
+	case "", RoleViewer, RoleVoter:
+	default:
+		return nil, fmt.Errorf("invalid anonymous role %q, want %s or %s", cfg.AnonymousRole, RoleViewer, RoleVoter)
+	}
+
+	var authenticators []Authenticator
+	if len(cfg.APIKeys) > 0 {
+		authenticators = append(authenticators, NewAPIKeys(cfg.APIKeys))
+	}
+
+	if cfg.JWT.Secret != "" || cfg.JWT.PublicKeyFile != "" {
+		jwtAuth, err := NewJWT(cfg.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure JWT authentication: %w", err)
+		}
+		authenticators = append(authenticators, jwtAuth)
+	}
+
+	if len(cfg.Roles) > 0 {
+		for i, authenticator := range authenticators {
+			authenticators[i] = grantRoles{Authenticator: authenticator, roles: cfg.Roles}
+		}
+	}
+
+	return authenticators, nil
+}
+
+// grantRoles adds the roles configured for a subject to those its
+// credentials carry, which is how principals of API keys get any.
+type grantRoles struct {
+	Authenticator
+	roles map[string]string
+}
+
+func (g grantRoles) Authenticate(r *http.Request) (*Principal, error) {
+	principal, err := g.Authenticator.Authenticate(r)
+	if err != nil || principal == nil {
+		return principal, err
+	}
+
+	if role, ok := g.roles[principal.Subject]; ok && !slices.Contains(principal.Roles, role) {
+		principal.Roles = append(principal.Roles, role)
+	}
+	return principal, nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"poll/configs"
+	"reflect"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	a := NewAPIKeys(map[string]string{"k1": "alice", "k2": "bob"})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    *Principal
+		wantErr bool
+	}{
+		{name: "no key"},
+		{name: "alice", key: "k1", want: &Principal{Subject: "alice", Method: "api_key"}},
+		{name: "bob", key: "k2", want: &Principal{Subject: "bob", Method: "api_key"}},
+		{name: "unknown key", key: "k3", wantErr: true},
+		{name: "prefix of a key", key: "k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+
+			got, err := a.Authenticate(r)
+			if tt.wantErr != errors.Is(err, ErrUnauthenticated) || (!tt.wantErr && err != nil) {
+				t.Fatalf("error = %v, want rejected %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("principal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGrantsRoles(t *testing.T) {
+	authenticators, err := New(configs.AuthConfig{
+		APIKeys: map[string]string{"k1": "alice", "k2": "bob"},
+		Roles:   map[string]string{"alice": RoleAdmin},
+		JWT:     configs.JWTConfig{Secret: testSecret},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticators: %v", err)
+	}
+	if len(authenticators) != 2 {
+		t.Fatalf("got %d authenticators, want API keys and JWT", len(authenticators))
+	}
+
+	authenticate := func(a Authenticator, header, value string) *Principal {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(header, value)
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			t.Fatalf("failed to authenticate: %v", err)
+		}
+		return principal
+	}
+
+	if p := authenticate(authenticators[0], APIKeyHeader, "k1"); !p.HasRole(RoleAdmin) || p.HasRole(RoleVoter) {
+		t.Errorf("alice has roles %v, want admin", p.Roles)
+	}
+	if p := authenticate(authenticators[0], APIKeyHeader, "k2"); len(p.Roles) != 0 || !p.HasRole(RoleVoter) {
+		t.Errorf("bob has roles %v, want to count as a voter", p.Roles)
+	}
+
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims())
+	if p := authenticate(authenticators[1], "Authorization", "Bearer "+token); !reflect.DeepEqual(p.Roles, []string{RoleAdmin}) {
+		t.Errorf("JWT of alice has roles %v, want admin once", p.Roles)
+	}
+}
+
+func TestNewWithoutCredentials(t *testing.T) {
+	authenticators, err := New(configs.AuthConfig{Roles: map[string]string{"alice": RoleAdmin}})
+	if err != nil || len(authenticators) != 0 {
+		t.Fatalf("got %d authenticators, %v, want none", len(authenticators), err)
+	}
+}
+
+func TestNewInvalidAnonymousRole(t *testing.T) {
+	if _, err := New(configs.AuthConfig{AnonymousRole: RoleAdmin}); err == nil {
+		t.Error("admin was accepted as the anonymous role")
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"os"
+	"poll/configs"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far the clocks of token issuers may be off.
+const clockSkew = 30 * time.Second
+
+// JWT authenticates requests by a bearer token in the Authorization header,
+// signed with HS256 by a shared secret or with RS256 by the holder of a
+// configured public key's private key. The token's subject becomes the
+// principal, and its "roles" claim the principal's roles.
+type JWT struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	parser    *jwt.Parser
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+func NewJWT(cfg configs.JWTConfig) (*JWT, error) {
+	a := &JWT{}
+	var methods []string
+
+	if cfg.Secret != "" {
+		a.secret = []byte(cfg.Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+		a.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(options...)
+
+	return a, nil
+}
+
+func (a *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+
+	var c claims
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), &c, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	return &Principal{Subject: c.Subject, Roles: c.Roles, Method: "jwt"}, nil
+}
+
+// key picks the verification key by the token's signing method, which the
+// parser has already checked is one of those configured.
+func (a *JWT) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return a.publicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
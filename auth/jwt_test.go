@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"poll/configs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// rsaKey generates a key pair and writes the public key to a PEM file.
+func rsaKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+	return key, path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, c jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "alice",
+		"roles": []string{RoleAdmin},
+		"iss":   "issuer",
+		"aud":   "poll",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestJWT(t *testing.T) {
+	privateKey, publicKeyFile := rsaKey(t)
+	otherKey, _ := rsaKey(t)
+	publicPEM, err := os.ReadFile(publicKeyFile)
+	if err != nil {
+		t.Fatalf("failed to read public key: %v", err)
+	}
+
+	a, err := NewJWT(configs.JWTConfig{
+		Secret:        testSecret,
+		PublicKeyFile: publicKeyFile,
+		Issuer:        "issuer",
+		Audience:      "poll",
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	with := func(change func(c jwt.MapClaims)) jwt.MapClaims {
+		c := validClaims()
+		change(c)
+		return c
+	}
+	hs256 := sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims())
+
+	tests := []struct {
+		name    string
+		header  string
+		want    *Principal
+		wantErr bool
+	}{
+		{name: "no header"},
+		{name: "other scheme", header: "Basic YWxpY2U6cGFzcw=="},
+		{
+			name:   "HS256",
+			header: "Bearer " + hs256,
+			want:   &Principal{Subject: "alice", Roles: []string{RoleAdmin}, Method: "jwt"},
+		},
+		{
+			name:   "RS256",
+			header: "Bearer " + sign(t, jwt.SigningMethodRS256, privateKey, validClaims()),
+			want:   &Principal{Subject: "alice", Roles: []string{RoleAdmin}, Method: "jwt"},
+		},
+		{
+			name:   "scheme is case-insensitive",
+			header: "bearer " + hs256,
+			want:   &Principal{Subject: "alice", Roles: []string{RoleAdmin}, Method: "jwt"},
+		},
+		{
+			name:    "expired",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+			wantErr: true,
+		},
+		{
+			name:   "expired within the clock skew",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-clockSkew / 2).Unix() })),
+			want:   &Principal{Subject: "alice", Roles: []string{RoleAdmin}, Method: "jwt"},
+		},
+		{
+			name:    "without expiry",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { delete(c, "exp") })),
+			wantErr: true,
+		},
+		{
+			name:    "not valid yet",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })),
+			wantErr: true,
+		},
+		{
+			name:    "wrong secret",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("other-secret"), validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			header:  "Bearer " + sign(t, jwt.SigningMethodRS256, otherKey, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "HS512",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS512, []byte(testSecret), validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "none",
+			header:  "Bearer " + sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "HS256 signed with the public key",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, publicPEM, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "tampered claims",
+			header:  "Bearer " + tamper(t, hs256, `{"sub":"mallory"}`),
+			wantErr: true,
+		},
+		{
+			name:    "tampered signature",
+			header:  "Bearer " + hs256[:len(hs256)-2] + "AA",
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { c["iss"] = "other" })),
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { c["aud"] = "other" })),
+			wantErr: true,
+		},
+		{
+			name:    "without subject",
+			header:  "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), with(func(c jwt.MapClaims) { delete(c, "sub") })),
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			header:  "Bearer not.a.token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			got, err := a.Authenticate(r)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnauthenticated) || got != nil {
+					t.Fatalf("got %+v, %v, want ErrUnauthenticated", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("principal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tamper replaces the claims of the token, keeping its signature.
+func tamper(t *testing.T, token, claims string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed token %s", token)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return strings.Join(parts, ".")
+}
+
+func TestJWTOnlyAcceptsConfiguredMethods(t *testing.T) {
+	privateKey, publicKeyFile := rsaKey(t)
+
+	hsOnly, err := NewJWT(configs.JWTConfig{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	rsOnly, err := NewJWT(configs.JWTConfig{PublicKeyFile: publicKeyFile})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		a     *JWT
+		token string
+	}{
+		{"RS256 without a public key", hsOnly, sign(t, jwt.SigningMethodRS256, privateKey, validClaims())},
+		{"HS256 without a secret", rsOnly, sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims())},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		if principal, err := tt.a.Authenticate(r); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: got %+v, %v, want ErrUnauthenticated", tt.name, principal, err)
+		}
+	}
+}
+
+func TestNewJWTInvalidPublicKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	if _, err := NewJWT(configs.JWTConfig{PublicKeyFile: path}); err == nil {
+		t.Error("invalid public key was accepted")
+	}
+	if _, err := NewJWT(configs.JWTConfig{PublicKeyFile: path + ".missing"}); err == nil {
+		t.Error("missing public key was accepted")
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Where voter tokens are sent: a cookie for browsers, a header for other
+// clients.
+const (
+	VoterTokenCookie = "voter_token"
+	VoterTokenHeader = "X-Voter-Token"
+)
+
+var ErrInvalidVoterToken = errors.New("invalid voter token")
+
+// VoterTokens issues and verifies voter tokens, which give voters without an
+// account an identity the server can trust: a random voter ID and expiry
+// signed with HMAC-SHA256, written as "<voter ID>.<expiry>.<signature>".
+type VoterTokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// VoterToken is an issued token and the voter ID it carries.
+type VoterToken struct {
+	Token     string
+	VoterID   string
+	ExpiresAt time.Time
+}
+
+func NewVoterTokens(secret string, ttl time.Duration) *VoterTokens {
+	return &VoterTokens{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}
+}
+
+// Issue returns a token for a new voter.
+func (v *VoterTokens) Issue() (*VoterToken, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate voter ID: %w", err)
+	}
+
+	voterID := base64.RawURLEncoding.EncodeToString(id)
+	expiresAt := time.Now().Add(v.ttl).Truncate(time.Second).UTC()
+	payload := voterID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return &VoterToken{
+		Token:     payload + "." + v.sign(payload),
+		VoterID:   voterID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Verify checks that the token was issued by this server and has not
+// expired yet.
+func (v *VoterTokens) Verify(token string) (*VoterToken, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidVoterToken)
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(v.sign(payload))) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidVoterToken)
+	}
+
+	voterID, expiry, ok := strings.Cut(payload, ".")
+	if !ok || voterID == "" {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidVoterToken)
+	}
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed expiry", ErrInvalidVoterToken)
+	}
+	expiresAt := time.Unix(seconds, 0).UTC()
+	if time.Now().After(expiresAt) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidVoterToken)
+	}
+
+	return &VoterToken{
+		Token:     token,
+		VoterID:   voterID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+type voterIDKey struct{}
+
+// WithVoterID returns a context carrying the voter ID of the request's
+// verified voter token.
+func WithVoterID(ctx context.Context, voterID string) context.Context {
+	return context.WithValue(ctx, voterIDKey{}, voterID)
+}
+
+// VoterIDFromContext returns the voter ID of the request's verified voter
+// token, if any.
+func VoterIDFromContext(ctx context.Context) (string, bool) {
+	voterID, ok := ctx.Value(voterIDKey{}).(string)
+	return voterID, ok && voterID != ""
+}
+
+func (v *VoterTokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
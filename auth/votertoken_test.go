@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoterTokens(t *testing.T) {
+	tokens := NewVoterTokens(testSecret, time.Hour)
+
+	issued, err := tokens.Issue()
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if issued.VoterID == "" || !strings.HasPrefix(issued.Token, issued.VoterID+".") {
+		t.Fatalf("token %s does not carry voter ID %s", issued.Token, issued.VoterID)
+	}
+	if until := time.Until(issued.ExpiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Fatalf("token expires in %s, want an hour", until)
+	}
+
+	verified, err := tokens.Verify(issued.Token)
+	if err != nil {
+		t.Fatalf("failed to verify token: %v", err)
+	}
+	if *verified != *issued {
+		t.Fatalf("verified %+v, want %+v", verified, issued)
+	}
+
+	other, err := tokens.Issue()
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if other.VoterID == issued.VoterID {
+		t.Fatalf("two tokens carry voter ID %s", issued.VoterID)
+	}
+}
+
+func TestVoterTokensReject(t *testing.T) {
+	tokens := NewVoterTokens(testSecret, time.Hour)
+	issued, err := tokens.Issue()
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	payload, signature := issued.Token[:strings.LastIndexByte(issued.Token, '.')], issued.Token[strings.LastIndexByte(issued.Token, '.')+1:]
+	expiry := strconv.FormatInt(issued.ExpiresAt.Unix(), 10)
+
+	expired, err := NewVoterTokens(testSecret, -time.Minute).Issue()
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	foreign, err := NewVoterTokens("other-secret", time.Hour).Issue()
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	unsignedID := tokens.sign(".") // a valid signature of a payload without voter ID
+	noExpiry := tokens.sign("voter")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no separators", "garbage"},
+		{"expired", expired.Token},
+		{"other secret", foreign.Token},
+		{"tampered voter ID", "mallory." + expiry + "." + signature},
+		{"extended expiry", issued.VoterID + "." + strconv.FormatInt(issued.ExpiresAt.Add(24*time.Hour).Unix(), 10) + "." + signature},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature))},
+		{"no signature", payload + "."},
+		{"no voter ID", "." + unsignedID},
+		{"no expiry", "voter." + noExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tokens.Verify(tt.token); !errors.Is(err, ErrInvalidVoterToken) {
+				t.Fatalf("got %+v, %v, want ErrInvalidVoterToken", got, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package configs
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,3 +20,85 @@ func (d *Duration) UnmarshalText(text []byte) error {
 	d.Duration = duration
 	return nil
 }
+
+// Rate is how many requests may be made per period, written as "10/1m".
+// Up to that many may be made at once; after that they are spread evenly
+// over the period. The zero Rate allows any number of requests.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*r = Rate{}
+		return nil
+	}
+
+	limit, period, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("invalid rate format %q, want requests/period", text)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid rate limit %q", limit)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid rate period %q", period)
+	}
+
+	*r = Rate{Limit: n, Period: d}
+	return nil
+}
+
+// Unlimited reports whether the rate allows any number of requests.
+func (r Rate) Unlimited() bool {
+	return r.Limit < 1 || r.Period <= 0
+}
+
+// Networks is a list of IP networks, written as comma-separated CIDRs such
+// as "10.0.0.0/8,192.168.1.1/32". A bare IP stands for itself alone.
+type Networks []*net.IPNet
+
+func (n *Networks) UnmarshalText(text []byte) error {
+	var networks Networks
+	for _, value := range strings.Split(string(text), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return fmt.Errorf("invalid network %q, want an IP or CIDR", value)
+		}
+		networks = append(networks, network)
+	}
+
+	*n = networks
+	return nil
+}
+
+// Contains reports whether ip, given as text, is in any of the networks.
+func (n Networks) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range n {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"poll/models"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(columns); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return cw, nil
+}
+
+func (c *csvWriter) Write(results models.PollResults, events []models.VoteEvent) error {
+	for _, row := range rows(results, events) {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			if cell != nil {
+				record[i] = fmt.Sprint(cell)
+			}
+		}
+		if err := c.w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"poll/models"
+	"time"
+)
+
+// Format is a file format results are exported as.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+// Formats lists the supported formats, CSV first as the default.
+var Formats = []Format{CSV, NDJSON, XLSX}
+
+// MediaType returns the media type of files in the format.
+func (f Format) MediaType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv"
+	}
+}
+
+// ContentType returns the Content-Type header of files in the format.
+func (f Format) ContentType() string {
+	if f == CSV {
+		return f.MediaType() + "; charset=utf-8"
+	}
+	return f.MediaType()
+}
+
+// Record kinds, which tell apart the rows of an export. NDJSON files hold
+// the whole results of a poll in one record instead of option and round
+// records.
+const (
+	RecordResults = "results"
+	RecordOption  = "option"
+	RecordRound   = "round"
+	RecordVote    = "vote"
+)
+
+// Writer writes the results of polls one after another, streaming them out
+// as it goes. Close must be called to complete the file.
+type Writer interface {
+	// Write writes the poll's results followed by its vote events, if any.
+	Write(results models.PollResults, events []models.VoteEvent) error
+	Close() error
+}
+
+// NewWriter returns a Writer of the format writing to w.
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w)
+	case NDJSON:
+		return newNDJSONWriter(w), nil
+	case XLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown export format %s", format)
+	}
+}
+
+// columns heads the table CSV and XLSX files hold. Every row is a record:
+// the votes of an option, its votes in a runoff round of a ranked poll, or
+// an option picked on a logged vote. Columns that do not apply to a record,
+// such as the votes of hidden results, are left empty.
+var columns = []string{
+	"record", "poll_id", "question", "status", "ballot_type", "option_id", "option_label",
+	"votes", "round", "vote_id", "user_id", "rank", "cast_at",
+}
+
+// rows lays the poll's results and vote events out as records. Cells are
+// strings or ints, or nil when empty.
+func rows(results models.PollResults, events []models.VoteEvent) [][]any {
+	labels := make(map[string]string, len(results.Options))
+	for _, option := range results.Options {
+		labels[option.ID] = option.Label
+	}
+
+	row := func(record, optionID string) []any {
+		return []any{
+			record, results.PollID, results.Question, string(results.Status), string(results.BallotType),
+			optionID, labels[optionID], nil, nil, nil, nil, nil, nil,
+		}
+	}
+
+	var rows [][]any
+	for _, option := range results.Options {
+		r := row(RecordOption, option.ID)
+		if results.Votes != nil {
+			r[7] = results.Votes[option.ID]
+		}
+		rows = append(rows, r)
+	}
+
+	for _, round := range results.Rounds {
+		for _, option := range results.Options {
+			votes, ok := round.Votes[option.ID]
+			if !ok {
+				continue
+			}
+			r := row(RecordRound, option.ID)
+			r[7], r[8] = votes, round.Round
+			rows = append(rows, r)
+		}
+	}
+
+	for _, event := range events {
+		for i, choice := range event.Choices() {
+			r := row(RecordVote, choice)
+			r[9], r[10], r[11], r[12] = event.ID, event.UserID, i+1, event.Timestamp.UTC().Format(time.RFC3339)
+			rows = append(rows, r)
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,205 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"poll/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	rankedResults = models.PollResults{
+		PollID:     "p1",
+		Question:   "Best, \"really\"?",
+		Options:    []models.Option{{ID: "a", Label: "A"}, {ID: "b", Label: "B <b>"}},
+		Votes:      map[string]int{"a": 2, "b": 1},
+		Status:     models.StatusClosed,
+		BallotType: models.BallotRanked,
+		Rounds:     []models.RunoffRound{{Round: 1, Votes: map[string]int{"a": 2, "b": 1}, Winner: "a"}},
+	}
+	hiddenResults = models.PollResults{
+		PollID:     "p2",
+		Question:   "Hidden?",
+		Options:    []models.Option{{ID: "c", Label: "C"}},
+		Status:     models.StatusOpen,
+		BallotType: models.BallotSingle,
+	}
+	rankedEvents = []models.VoteEvent{{
+		ID:        "e1",
+		PollID:    "p1",
+		UserID:    "alice",
+		Options:   []string{"b", "a"},
+		Timestamp: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}}
+)
+
+// wantTable is the table CSV and XLSX files hold for rankedResults with
+// rankedEvents, followed by hiddenResults.
+var wantTable = [][]string{
+	columns,
+	{"option", "p1", "Best, \"really\"?", "closed", "ranked", "a", "A", "2", "", "", "", "", ""},
+	{"option", "p1", "Best, \"really\"?", "closed", "ranked", "b", "B <b>", "1", "", "", "", "", ""},
+	{"round", "p1", "Best, \"really\"?", "closed", "ranked", "a", "A", "2", "1", "", "", "", ""},
+	{"round", "p1", "Best, \"really\"?", "closed", "ranked", "b", "B <b>", "1", "1", "", "", "", ""},
+	{"vote", "p1", "Best, \"really\"?", "closed", "ranked", "b", "B <b>", "", "", "e1", "alice", "1", "2030-01-02T03:04:05Z"},
+	{"vote", "p1", "Best, \"really\"?", "closed", "ranked", "a", "A", "", "", "e1", "alice", "2", "2030-01-02T03:04:05Z"},
+	{"option", "p2", "Hidden?", "open", "single", "c", "C", "", "", "", "", "", ""},
+}
+
+// export writes the test polls in the format.
+func export(t *testing.T, format Format) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if err := w.Write(rankedResults, rankedEvents); err != nil {
+		t.Fatalf("failed to write results: %v", err)
+	}
+	if err := w.Write(hiddenResults, nil); err != nil {
+		t.Fatalf("failed to write results: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	table, err := csv.NewReader(bytes.NewReader(export(t, CSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if !reflect.DeepEqual(table, wantTable) {
+		t.Fatalf("got table\n%q\nwant\n%q", table, wantTable)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(export(t, NDJSON))), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	var results resultsLine
+	if err := json.Unmarshal([]byte(lines[0]), &results); err != nil {
+		t.Fatalf("failed to decode line 1: %v", err)
+	}
+	if results.Record != RecordResults || !reflect.DeepEqual(results.PollResults, rankedResults) {
+		t.Errorf("line 1 = %+v, want the results of p1", results)
+	}
+
+	var vote voteLine
+	if err := json.Unmarshal([]byte(lines[1]), &vote); err != nil {
+		t.Fatalf("failed to decode line 2: %v", err)
+	}
+	if vote.Record != RecordVote || !reflect.DeepEqual(vote.VoteEvent, rankedEvents[0]) {
+		t.Errorf("line 2 = %+v, want vote event e1", vote)
+	}
+
+	var hidden map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &hidden); err != nil {
+		t.Fatalf("failed to decode line 3: %v", err)
+	}
+	if hidden["record"] != RecordResults || hidden["poll_id"] != "p2" || hidden["votes"] != nil {
+		t.Errorf("line 3 = %v, want the results of p2 without votes", hidden)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	data := export(t, XLSX)
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open workbook: %v", err)
+	}
+
+	parts := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		parts[f.Name], err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+	}
+	for _, part := range xlsxParts {
+		if string(parts[part.name]) != part.content {
+			t.Errorf("part %s is missing or changed", part.name)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("failed to parse worksheet: %v", err)
+	}
+
+	if len(sheet.Rows) != len(wantTable) {
+		t.Fatalf("got %d rows, want %d", len(sheet.Rows), len(wantTable))
+	}
+	for i, row := range sheet.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %d is numbered %d", i+1, row.R)
+		}
+		got := make([]string, len(columns))
+		for _, cell := range row.Cells {
+			column := -1
+			for j := range columns {
+				if cellRef(j, row.R) == cell.R {
+					column = j
+				}
+			}
+			if column < 0 {
+				t.Fatalf("row %d has a cell at %s", row.R, cell.R)
+			}
+			if cell.Type == "inlineStr" {
+				got[column] = cell.Inline
+			} else {
+				got[column] = cell.Value
+			}
+		}
+		if !reflect.DeepEqual(got, wantTable[i]) {
+			t.Errorf("row %d = %q, want %q", row.R, got, wantTable[i])
+		}
+	}
+}
+
+func TestCellRef(t *testing.T) {
+	tests := []struct {
+		column, row int
+		want        string
+	}{
+		{0, 1, "A1"},
+		{12, 7, "M7"},
+		{25, 2, "Z2"},
+		{26, 3, "AA3"},
+		{51, 4, "AZ4"},
+		{52, 5, "BA5"},
+		{701, 6, "ZZ6"},
+		{702, 7, "AAA7"},
+	}
+	for _, tt := range tests {
+		if got := cellRef(tt.column, tt.row); got != tt.want {
+			t.Errorf("cellRef(%d, %d) = %s, want %s", tt.column, tt.row, got, tt.want)
+		}
+	}
+}
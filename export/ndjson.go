@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"poll/models"
+)
+
+// ndjsonWriter writes one JSON object per line: the results of a poll in
+// the shape pushed to live subscribers, then each of its vote events, told
+// apart by their "record" field.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+type resultsLine struct {
+	Record string `json:"record"`
+	models.PollResults
+}
+
+type voteLine struct {
+	Record string `json:"record"`
+	models.VoteEvent
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(results models.PollResults, events []models.VoteEvent) error {
+	if err := n.enc.Encode(resultsLine{Record: RecordResults, PollResults: results}); err != nil {
+		return fmt.Errorf("failed to write results of poll %s: %w", results.PollID, err)
+	}
+
+	for _, event := range events {
+		if err := n.enc.Encode(voteLine{Record: RecordVote, VoteEvent: event}); err != nil {
+			return fmt.Errorf("failed to write vote event %s: %w", event.ID, err)
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"poll/auth"
+	httpServer "poll/server/http"
+	"strconv"
+	"strings"
+)
+
+// runImport implements "poll import": it sends a JSON or CSV file of polls
+// to the import endpoint of a running server and prints the outcome for
+// every poll. It returns the exit code, which is 1 if any poll failed.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	server := flags.String("server", "http://localhost:8080", "base URL of the poll server")
+	apiKey := flags.String("api-key", os.Getenv("POLL_API_KEY"), "API key to authenticate with, defaults to $POLL_API_KEY")
+	token := flags.String("token", os.Getenv("POLL_TOKEN"), "JWT to authenticate with, defaults to $POLL_TOKEN")
+	format := flags.String("format", "", "file format, json or csv; taken from the file extension by default")
+	atomic := flags.Bool("atomic", false, "only create the polls if all are valid")
+	dryRun := flags.Bool("dry-run", false, "only validate the polls")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s import [flags] <file|->\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	resp, err := sendImport(flags.Arg(0), *server, *apiKey, *token, *format, *atomic, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	for _, row := range resp.Rows {
+		switch {
+		case row.Error != nil:
+			fmt.Printf("row %d: %s: %s\n", row.Row, row.Status, describeError(*row.Error))
+		case row.PollID != "":
+			fmt.Printf("row %d: %s %s\n", row.Row, row.Status, row.PollID)
+		default:
+			fmt.Printf("row %d: %s\n", row.Row, row.Status)
+		}
+	}
+	fmt.Printf("%d created, %d failed\n", resp.Created, resp.Failed)
+
+	if resp.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// sendImport posts the file, or stdin for "-", to the server's import
+// endpoint and returns its report.
+func sendImport(path, server, apiKey, token, format string, atomic, dryRun bool) (*httpServer.ImportResponse, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	var contentType string
+	switch format {
+	case "json":
+		contentType = "application/json"
+	case "csv":
+		contentType = "text/csv"
+	default:
+		return nil, fmt.Errorf("unknown format %q, use -format json or csv", format)
+	}
+
+	body := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = f
+	}
+
+	endpoint, err := url.JoinPath(server, "polls", "import")
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	query := url.Values{
+		"atomic":  {strconv.FormatBool(atomic)},
+		"dry_run": {strconv.FormatBool(dryRun)},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		var errResp httpServer.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+			return nil, fmt.Errorf("server responded %s", resp.Status)
+		}
+		return nil, fmt.Errorf("server responded %s: %s", resp.Status, describeError(errResp))
+	}
+
+	var report httpServer.ImportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to read import report: %w", err)
+	}
+	return &report, nil
+}
+
+func describeError(resp httpServer.ErrorResponse) string {
+	message := resp.Code + ": " + resp.Message
+	if resp.Field != "" {
+		message += " (" + resp.Field + ")"
+	}
+	for _, fieldErr := range resp.Errors {
+		message += "; " + fieldErr.Field + " " + fieldErr.Message
+	}
+	return message
+}
@@ -4,15 +4,37 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"poll/auth"
 	"poll/configs"
-	"poll/models"
+	"poll/ratelimit"
+	memoryLimiter "poll/ratelimit/memory"
+	redisLimiter "poll/ratelimit/redis"
+	"poll/repo"
+	memoryRepo "poll/repo/memory"
+	"poll/repo/postgres"
 	"poll/repo/redis"
 	httpServer "poll/server/http"
 	"poll/server/websocket"
+	"poll/service"
 	"poll/service/basic"
+	"poll/service/results/memory"
+	redisResults "poll/service/results/redis"
 )
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and a JWT.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -21,29 +43,64 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
-	redisClient, err := redis.New(ctx, config.Repo)
+	repository, err := newRepository(ctx, config.Repo)
 	if err != nil {
-		log.Fatalf("failed to create Redis client: %v", err)
+		log.Fatalf("failed to create repository: %v", err)
 	}
 	defer func() {
-		if err := redisClient.Close(); err != nil {
-			log.Fatalf("failed to close Redis client: %v", err)
+		if err := repository.Close(); err != nil {
+			log.Fatalf("failed to close repository: %v", err)
 		}
 	}()
 
-	results := make(chan models.PollResults)
-	defer close(results)
+	publisher, err := newResultsPublisher(ctx, config)
+	if err != nil {
+		log.Fatalf("failed to create results publisher: %v", err)
+	}
+	defer func() {
+		if err := publisher.Close(); err != nil {
+			log.Printf("failed to close results publisher: %v", err)
+		}
+	}()
 
-	pollService := basic.NewService(redisClient, results)
+	results, err := publisher.Subscribe(ctx)
+	if err != nil {
+		log.Fatalf("failed to subscribe to poll results: %v", err)
+	}
+
+	pollService := basic.NewService(repository, repository, publisher)
+
+	scheduler := basic.NewScheduler(pollService, config.Srv.Scheduler.Interval.Duration)
+	go scheduler.Start(ctx)
+
+	authenticators, err := auth.New(config.HTTP.Auth)
+	if err != nil {
+		log.Fatalf("failed to configure authentication: %v", err)
+	}
+	if len(authenticators) == 0 {
+		log.Printf("authentication is disabled: configure AUTH_API_KEYS or a JWT key to protect write endpoints")
+	}
 
-	httpSrv := httpServer.NewServer(log.Default(), pollService)
+	limiter, err := newRateLimiter(ctx, config)
+	if err != nil {
+		log.Fatalf("failed to create rate limiter: %v", err)
+	}
+	if limiter != nil {
+		defer func() {
+			if err := limiter.Close(); err != nil {
+				log.Printf("failed to close rate limiter: %v", err)
+			}
+		}()
+	}
+
+	httpSrv := httpServer.NewServer(log.Default(), pollService, config.HTTP, authenticators, limiter)
 	go func() {
 		if err := httpSrv.Start(ctx); err != nil {
 			log.Fatalf("HTTP server failed: %v", err)
 		}
 	}()
 
-	wsSrv := websocket.New(log.Default(), results)
+	wsSrv := websocket.New(log.Default(), results, pollService)
 	go func() {
 		if err := wsSrv.Start(fmt.Sprintf("0.0.0.0:%s", config.Srv.Monitoring.WebSocket.Port)); err != nil {
 			log.Fatalf("WebSocket server failed: %v", err)
@@ -52,3 +109,53 @@ func main() {
 
 	<-ctx.Done()
 }
+
+// storage is a repository that also keeps the vote event log.
+type storage interface {
+	repo.Repository
+	repo.VoteEventLog
+}
+
+func newRepository(ctx context.Context, cfg configs.RepoConfig) (storage, error) {
+	switch cfg.Backend {
+	case "redis":
+		return redis.New(ctx, cfg)
+	case "postgres":
+		return postgres.New(ctx, cfg)
+	case "memory":
+		log.Printf("using the in-memory repository, polls are lost on restart")
+		return memoryRepo.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown repository backend: %s", cfg.Backend)
+	}
+}
+
+func newResultsPublisher(ctx context.Context, config *configs.AppConfig) (service.ResultsPublisher, error) {
+	cfg := config.Srv.Results
+
+	switch cfg.Publisher {
+	case "memory":
+		return memory.New(cfg.BufferSize, memory.OverflowPolicy(cfg.OverflowPolicy))
+	case "redis":
+		return redisResults.New(ctx, config.Repo.Redis, cfg.RedisChannel, cfg.BufferSize)
+	default:
+		return nil, fmt.Errorf("unknown results publisher: %s", cfg.Publisher)
+	}
+}
+
+// newRateLimiter returns the configured rate limiter, or nil if rate limiting
+// is turned off.
+func newRateLimiter(ctx context.Context, config *configs.AppConfig) (ratelimit.Limiter, error) {
+	cfg := config.HTTP.RateLimit
+
+	switch cfg.Limiter {
+	case "memory":
+		return memoryLimiter.New(), nil
+	case "redis":
+		return redisLimiter.New(ctx, config.Repo.Redis, cfg.KeyPrefix)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter: %s", cfg.Limiter)
+	}
+}
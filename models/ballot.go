@@ -0,0 +1,38 @@
+package models
+
+type BallotType string
+
+const (
+	// BallotSingle lets every voter pick exactly one option.
+	BallotSingle BallotType = "single"
+	// BallotMulti lets every voter pick between MinChoices and MaxChoices
+	// options, each counted once.
+	BallotMulti BallotType = "multi"
+	// BallotRanked lets every voter rank options by preference. Votes count
+	// first preferences; the winner is decided by instant runoff.
+	BallotRanked BallotType = "ranked"
+)
+
+// Ballot is what the repository stores for a voter: their choices in the
+// order given, which for ranked ballots is their order of preference.
+type Ballot struct {
+	UserID  string
+	Choices []string
+	Ranked  bool
+}
+
+// Counted returns the options whose vote counters the ballot adds to.
+func (b Ballot) Counted() []string {
+	if b.Ranked && len(b.Choices) > 0 {
+		return b.Choices[:1]
+	}
+	return b.Choices
+}
+
+// RunoffRound is one round of an instant-runoff tally of a ranked poll.
+type RunoffRound struct {
+	Round      int            `json:"round"`
+	Votes      map[string]int `json:"votes"`
+	Eliminated []string       `json:"eliminated,omitempty"`
+	Winner     string         `json:"winner,omitempty"`
+}
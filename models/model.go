@@ -1,17 +1,227 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
+type PollStatus string
+
+const (
+	StatusDraft    PollStatus = "draft"
+	StatusOpen     PollStatus = "open"
+	StatusClosed   PollStatus = "closed"
+	StatusArchived PollStatus = "archived"
+)
+
+// ResultsVisibility decides who may see a poll's vote counts. Its owner and
+// admins always may.
+type ResultsVisibility string
+
+const (
+	// ResultsAlways shows vote counts to everyone at all times.
+	ResultsAlways ResultsVisibility = "always"
+	// ResultsAfterVote shows vote counts to voters once they have voted,
+	// and to everyone once the poll is closed.
+	ResultsAfterVote ResultsVisibility = "after_vote"
+	// ResultsAfterClose shows vote counts to everyone once the poll is
+	// closed.
+	ResultsAfterClose ResultsVisibility = "after_close"
+	// ResultsOwnerOnly never shows vote counts to anyone else.
+	ResultsOwnerOnly ResultsVisibility = "owner_only"
+)
+
+// Reveals reports whether vote counts of a poll in the given status are
+// shown to someone other than its owner, who has or has not voted in it.
+func (v ResultsVisibility) Reveals(status PollStatus, voted bool) bool {
+	closed := status == StatusClosed || status == StatusArchived
+	switch v {
+	case ResultsAfterVote:
+		return voted || closed
+	case ResultsAfterClose:
+		return closed
+	case ResultsOwnerOnly:
+		return false
+	default:
+		return true
+	}
+}
+
+// Poll is a poll with its current votes. Version counts the writes of the
+// poll's details, starting at 1; votes do not change it.
 type Poll struct {
-	ID       uuid.UUID      `json:"id"`
-	Question string         `json:"question"`
-	Options  []string       `json:"options"`
-	Votes    map[string]int `json:"votes"`
+	ID                uuid.UUID         `json:"id"`
+	Question          string            `json:"question"`
+	Options           []Option          `json:"options"`
+	Votes             map[string]int    `json:"votes"`
+	AllowVoteChange   bool              `json:"allow_vote_change"`
+	Status            PollStatus        `json:"status"`
+	OpensAt           *time.Time        `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time        `json:"closes_at,omitempty"`
+	BallotType        BallotType        `json:"ballot_type"`
+	MinChoices        int               `json:"min_choices,omitempty"`
+	MaxChoices        int               `json:"max_choices,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	CreatedBy         string            `json:"created_by,omitempty"`
+	Tags              []string          `json:"tags,omitempty"`
+	ResultsVisibility ResultsVisibility `json:"results_visibility"`
+	Version           int64             `json:"version"`
+}
+
+// PollSort is the order polls are listed in.
+type PollSort string
+
+const (
+	SortCreated PollSort = "created"
+	SortVotes   PollSort = "votes"
+)
+
+// PollQuery selects a page of polls. Cursor is the NextCursor of the
+// previous page, empty for the first one. Zero-valued filters match every
+// poll; Tags only match polls carrying all of them, and Search matches polls
+// with a word in the question or options starting with each word of it.
+type PollQuery struct {
+	Cursor        string
+	Limit         int
+	Status        PollStatus
+	CreatedBy     string
+	Tags          []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Search        string
+	Sort          PollSort
+	Ascending     bool
+}
+
+// PollPage is a page of polls in the order requested by the query.
+type PollPage struct {
+	Polls      []Poll `json:"polls"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NextTransition returns when the poll's status is next due to change on
+// schedule, if ever.
+func (p *Poll) NextTransition() (time.Time, bool) {
+	switch {
+	case p.Status == StatusDraft && p.OpensAt != nil:
+		return *p.OpensAt, true
+	case p.Status == StatusOpen && p.ClosesAt != nil:
+		return *p.ClosesAt, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Vote is a voter's ballot. Single-choice polls take Option, multi-select
+// and ranked polls take Options, ranked ones in order of preference.
+type Vote struct {
+	UserID string `json:"user_id"`
+	// Subject is who the voter authenticated as and VoterID their identity
+	// as verified by a voter token. Either identifies the voter instead of
+	// the client-chosen UserID, Subject first.
+	Subject   string   `json:"-"`
+	VoterID   string   `json:"-"`
+	Option    string   `json:"option"`
+	Options   []string `json:"options"`
+	ClientIP  string   `json:"client_ip"`
+	UserAgent string   `json:"user_agent"`
+}
+
+// Prefixes of the identities ballots are counted under, one per kind of
+// identity, so that a user ID never passes for a verified identity.
+const (
+	VoterSubject = "sub:"
+	VoterToken   = "vt:"
+	VoterUserID  = "uid:"
+)
+
+// Voter returns the identity the vote is counted under, or "" if the vote
+// has none.
+func (v Vote) Voter() string {
+	switch {
+	case v.Subject != "":
+		return VoterSubject + v.Subject
+	case v.VoterID != "":
+		return VoterToken + v.VoterID
+	case v.UserID != "":
+		return VoterUserID + v.UserID
+	default:
+		return ""
+	}
+}
+
+// Choices returns the options picked on the ballot, whichever field was used.
+func (v Vote) Choices() []string {
+	return choices(v.Option, v.Options)
+}
+
+// VoteEvent is a single accepted vote as recorded in the vote event log.
+type VoteEvent struct {
+	ID        string    `json:"id"`
+	PollID    string    `json:"poll_id"`
+	UserID    string    `json:"user_id"`
+	Option    string    `json:"option,omitempty"`
+	Options   []string  `json:"options,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Choices returns the options picked on the logged ballot.
+func (e VoteEvent) Choices() []string {
+	return choices(e.Option, e.Options)
+}
+
+func choices(option string, options []string) []string {
+	if len(options) > 0 {
+		return options
+	}
+	if option != "" {
+		return []string{option}
+	}
+	return nil
+}
+
+// VoteReplay is the outcome of rebuilding a poll's vote counts from its
+// event log.
+type VoteReplay struct {
+	PollID  string         `json:"poll_id"`
+	Events  int            `json:"events"`
+	Votes   map[string]int `json:"votes"`
+	Applied bool           `json:"applied"`
 }
 
 type PollResults struct {
-	PollID   string         `json:"poll_id"`
-	Question string         `json:"question"`
-	Options  []string       `json:"options"`
-	Votes    map[string]int `json:"votes"`
+	PollID     string         `json:"poll_id"`
+	Question   string         `json:"question"`
+	Options    []Option       `json:"options"`
+	Votes      map[string]int `json:"votes"`
+	Status     PollStatus     `json:"status"`
+	Final      bool           `json:"final"`
+	BallotType BallotType     `json:"ballot_type"`
+	Rounds     []RunoffRound  `json:"rounds,omitempty"`
+	// Visibility is the poll's results visibility; Votes and Rounds are
+	// left out of results that are not visible.
+	Visibility ResultsVisibility `json:"results_visibility"`
+}
+
+// Redact leaves the vote counts out of the results.
+func (r *PollResults) Redact() {
+	r.Votes = nil
+	r.Rounds = nil
+}
+
+// Results returns the current results of the poll in the shape that is
+// pushed to live subscribers. Runoff rounds of ranked polls depend on the
+// individual ballots and are left for the caller to fill in.
+func (p *Poll) Results() PollResults {
+	return PollResults{
+		PollID:     p.ID.String(),
+		Question:   p.Question,
+		Options:    p.Options,
+		Votes:      p.Votes,
+		Status:     p.Status,
+		BallotType: p.BallotType,
+		Visibility: p.ResultsVisibility,
+	}
 }
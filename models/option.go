@@ -0,0 +1,28 @@
+package models
+
+import "github.com/google/uuid"
+
+// Option is one answer of a poll. Votes refer to options by ID, so the
+// label and the rest can be edited without losing votes.
+type Option struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	Order       int    `json:"order"`
+}
+
+// LegacyOptionID derives the ID given to an option of a poll stored before
+// options had IDs, when all that identified it was its label.
+func LegacyOptionID(pollID uuid.UUID, label string) string {
+	return uuid.NewSHA1(pollID, []byte(label)).String()
+}
+
+// OptionIDs returns the IDs of the options in order.
+func OptionIDs(options []Option) []string {
+	ids := make([]string, len(options))
+	for i, option := range options {
+		ids[i] = option.ID
+	}
+	return ids
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"poll/ratelimit"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often keys whose requests no longer count are
+// dropped.
+const sweepInterval = time.Minute
+
+// Limiter counts requests in process memory, so replicas do not share
+// counts. It suits single-instance deployments.
+type Limiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		tats:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *Limiter) Allow(_ context.Context, rules ...ratelimit.Rule) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	tats := make([]time.Time, len(rules))
+	var wait time.Duration
+	for i, rule := range rules {
+		if rule.Rate.Unlimited() {
+			continue
+		}
+		var retryAfter time.Duration
+		tats[i], retryAfter = ratelimit.GCRA(l.tats[rule.Key], now, rule.Rate)
+		wait = max(wait, retryAfter)
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for i, rule := range rules {
+		if !rule.Rate.Unlimited() {
+			l.tats[rule.Key] = tats[i]
+		}
+	}
+	return 0, nil
+}
+
+// sweep drops the keys whose TAT has passed, as they are no different from
+// keys never seen.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, tat := range l.tats {
+		if !tat.After(now) {
+			delete(l.tats, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *Limiter) Close() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"poll/configs"
+	"poll/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+
+	strict := ratelimit.Rule{Key: "strict", Rate: configs.Rate{Limit: 1, Period: time.Minute}}
+	loose := ratelimit.Rule{Key: "loose", Rate: configs.Rate{Limit: 2, Period: time.Minute}}
+	unlimited := ratelimit.Rule{Key: "unlimited"}
+	allow := func(rules ...ratelimit.Rule) time.Duration {
+		t.Helper()
+		retryAfter, err := l.Allow(context.Background(), rules...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return retryAfter
+	}
+
+	if got := allow(strict, loose, unlimited); got != 0 {
+		t.Fatalf("first request held back for %s", got)
+	}
+	if got := allow(loose, strict); got != time.Minute {
+		t.Fatalf("second request held back for %s, want 1m", got)
+	}
+	// The request held back by the strict rule was not counted against the
+	// loose one, which still allows a second request.
+	if got := allow(loose); got != 0 {
+		t.Fatalf("second request of the loose rule held back for %s", got)
+	}
+	if got := allow(loose); got != 30*time.Second {
+		t.Fatalf("third request of the loose rule held back for %s, want 30s", got)
+	}
+	if _, ok := l.tats[unlimited.Key]; ok {
+		t.Error("unlimited rule was counted")
+	}
+
+	now = now.Add(time.Minute)
+	if got := allow(strict, loose); got != 0 {
+		t.Fatalf("request after a minute held back for %s", got)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	rate := configs.Rate{Limit: 10, Period: time.Second}
+	if _, err := l.Allow(context.Background(), ratelimit.Rule{Key: "a", Rate: rate}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(sweepInterval)
+	if _, err := l.Allow(context.Background(), ratelimit.Rule{Key: "b", Rate: rate}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := l.tats["a"]; ok || len(l.tats) != 1 {
+		t.Errorf("got keys %v, want only b", l.tats)
+	}
+}
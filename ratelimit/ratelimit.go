@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"poll/configs"
+	"time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Rule applies a rate to the requests sharing a key, such as a client IP.
+type Rule struct {
+	Key  string
+	Rate configs.Rate
+}
+
+// Limiter counts requests against keys and holds back those that exceed a
+// rate.
+type Limiter interface {
+	// Allow counts a request against every rule. It returns how long to
+	// wait before retrying if the request exceeds the rate of any of them,
+	// or 0 if it may proceed. Requests held back are not counted against
+	// any rule.
+	Allow(ctx context.Context, rules ...Rule) (time.Duration, error)
+	Close() error
+}
+
+// GCRA applies the generic cell rate algorithm, a token bucket that only
+// keeps the theoretical arrival time (TAT) of the next request: each request
+// pushes it back by Period/Limit, and requests arriving more than Period
+// before it are held back. It returns the TAT to keep and how long to wait
+// before retrying, which is 0 if the request may proceed.
+func GCRA(tat, now time.Time, rate configs.Rate) (time.Time, time.Duration) {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(rate.Period / time.Duration(rate.Limit))
+	if allowAt := next.Add(-rate.Period); now.Before(allowAt) {
+		return tat, allowAt.Sub(now)
+	}
+	return next, 0
+}
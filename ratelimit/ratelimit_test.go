@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"poll/configs"
+	"testing"
+	"time"
+)
+
+func TestGCRA(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	rate := configs.Rate{Limit: 3, Period: 3 * time.Second}
+
+	// Each step is a request at the offset from start.
+	tests := []struct {
+		name    string
+		at      time.Duration
+		wantTAT time.Duration
+		want    time.Duration
+	}{
+		{"first of the burst", 0, time.Second, 0},
+		{"second of the burst", 0, 2 * time.Second, 0},
+		{"last of the burst", 0, 3 * time.Second, 0},
+		{"over the burst", 0, 3 * time.Second, time.Second},
+		{"still over the burst", 500 * time.Millisecond, 3 * time.Second, 500 * time.Millisecond},
+		{"after one interval", time.Second, 4 * time.Second, 0},
+		{"again too soon", 1500 * time.Millisecond, 4 * time.Second, 500 * time.Millisecond},
+		{"after idling, a full burst", time.Minute, time.Minute + time.Second, 0},
+	}
+
+	var tat time.Time
+	for _, tt := range tests {
+		var retryAfter time.Duration
+		tat, retryAfter = GCRA(tat, start.Add(tt.at), rate)
+		if got := tat.Sub(start); got != tt.wantTAT || retryAfter != tt.want {
+			t.Fatalf("%s: got TAT +%s and retry after %s, want +%s and %s", tt.name, got, retryAfter, tt.wantTAT, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"poll/configs"
+	"poll/ratelimit"
+	"time"
+)
+
+// allowScript applies ratelimit.GCRA to the TATs stored at KEYS, in
+// microseconds of the Redis server's clock so replicas with skewed clocks
+// agree. ARGV holds each key's rate as its interval and period in
+// microseconds. It returns how many microseconds to wait before retrying, 0
+// if the request may proceed, and only then stores the new TATs.
+var allowScript = redis.NewScript(`
+redis.replicate_commands()
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000000 + tonumber(clock[2])
+
+local new_tats = {}
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local interval = tonumber(ARGV[2 * i - 1])
+	local period = tonumber(ARGV[2 * i])
+
+	local tat = tonumber(redis.call('GET', key) or now)
+	if tat < now then
+		tat = now
+	end
+
+	new_tats[i] = tat + interval
+	local allow_at = new_tats[i] - period
+	if allow_at - now > wait then
+		wait = allow_at - now
+	end
+end
+if wait > 0 then
+	return wait
+end
+
+for i, key in ipairs(KEYS) do
+	redis.call('SET', key, string.format('%.0f', new_tats[i]), 'PX', math.ceil((new_tats[i] - now) / 1000))
+end
+return 0
+`)
+
+// Limiter counts requests in Redis, so every replica holds back requests
+// counted by any other.
+type Limiter struct {
+	client *redis.Client
+	prefix string
+}
+
+func New(ctx context.Context, cfg configs.RedisConfig, prefix string) (*Limiter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		Username: cfg.Username,
+		DB:       cfg.DB,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("basic connection failure: %v", err)
+	}
+
+	return &Limiter{
+		client: client,
+		prefix: prefix,
+	}, nil
+}
+
+func (l *Limiter) Allow(ctx context.Context, rules ...ratelimit.Rule) (time.Duration, error) {
+	var keys []string
+	var args []interface{}
+	for _, rule := range rules {
+		if rule.Rate.Unlimited() {
+			continue
+		}
+		interval := (rule.Rate.Period / time.Duration(rule.Rate.Limit)).Microseconds()
+		if interval < 1 {
+			interval = 1
+		}
+		keys = append(keys, l.prefix+rule.Key)
+		args = append(args, interval, rule.Rate.Period.Microseconds())
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	wait, err := allowScript.Run(ctx, l.client, keys, args...).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply rate limit: %w", err)
+	}
+	return time.Duration(wait) * time.Microsecond, nil
+}
+
+func (l *Limiter) Close() error {
+	return l.client.Close()
+}
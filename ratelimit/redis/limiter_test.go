@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"poll/configs"
+	"poll/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	m := miniredis.RunT(t)
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.SetTime(now)
+
+	l, err := New(context.Background(), configs.RedisConfig{Addr: m.Addr()}, "rl:")
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	strict := ratelimit.Rule{Key: "strict", Rate: configs.Rate{Limit: 1, Period: time.Minute}}
+	loose := ratelimit.Rule{Key: "loose", Rate: configs.Rate{Limit: 2, Period: time.Minute}}
+	allow := func(rules ...ratelimit.Rule) time.Duration {
+		t.Helper()
+		retryAfter, err := l.Allow(context.Background(), rules...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return retryAfter
+	}
+
+	if got := allow(strict, loose, ratelimit.Rule{Key: "unlimited"}); got != 0 {
+		t.Fatalf("first request held back for %s", got)
+	}
+	if m.Exists("rl:unlimited") || !m.Exists("rl:strict") {
+		t.Fatalf("got keys %v, want the TATs of the limited rules", m.Keys())
+	}
+	if got := allow(loose, strict); got != time.Minute {
+		t.Fatalf("second request held back for %s, want 1m", got)
+	}
+	if got := allow(loose); got != 0 {
+		t.Fatalf("second request of the loose rule held back for %s", got)
+	}
+	if got := allow(loose); got != 30*time.Second {
+		t.Fatalf("third request of the loose rule held back for %s, want 30s", got)
+	}
+
+	m.SetTime(now.Add(time.Minute))
+	if got := allow(strict, loose); got != 0 {
+		t.Fatalf("request after a minute held back for %s", got)
+	}
+	if got := allow(); got != 0 {
+		t.Fatalf("request without rules held back for %s", got)
+	}
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// Cursor points at the last poll of a page: the value the listing is sorted
+// by and the poll ID, which breaks ties.
+type Cursor struct {
+	Score  float64
+	Member string
+}
+
+func (c Cursor) Encode() string {
+	raw := strconv.FormatFloat(c.Score, 'f', -1, 64) + ":" + c.Member
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses an encoded cursor. An empty value, for the first page,
+// yields nil.
+func DecodeCursor(value string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	score, member, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	parsed, err := strconv.ParseFloat(score, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Score: parsed, Member: member}, nil
+}
+
+// After reports whether an entry comes after the cursor among entries sharing
+// its score, which are ordered by member in the listing's direction.
+func (c Cursor) After(member string, ascending bool) bool {
+	if ascending {
+		return member > c.Member
+	}
+	return member < c.Member
+}
@@ -0,0 +1,396 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"poll/models"
+	"poll/repo"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultPageSize = 20
+
+// Repository keeps polls, votes and vote events in process memory. It is
+// meant for tests and local development: nothing survives a restart and
+// replicas do not share state.
+type Repository struct {
+	mu     sync.RWMutex
+	polls  map[string]*entry
+	events map[string][]models.VoteEvent
+	nextID int64
+}
+
+type entry struct {
+	poll    models.Poll
+	votes   map[string]int
+	ballots map[string][]string
+}
+
+func New() *Repository {
+	return &Repository{
+		polls:  make(map[string]*entry),
+		events: make(map[string][]models.VoteEvent),
+	}
+}
+
+func (s *Repository) Close() error {
+	return nil
+}
+
+func (s *Repository) CreatePoll(ctx context.Context, pollID string, poll models.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.polls[pollID]; ok {
+		return fmt.Errorf("poll %s already exists", pollID)
+	}
+
+	s.createPoll(pollID, poll)
+	return nil
+}
+
+func (s *Repository) CreatePolls(ctx context.Context, polls []models.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(polls))
+	for _, poll := range polls {
+		pollID := poll.ID.String()
+		if _, ok := s.polls[pollID]; ok || seen[pollID] {
+			return fmt.Errorf("poll %s already exists", pollID)
+		}
+		seen[pollID] = true
+	}
+
+	for _, poll := range polls {
+		s.createPoll(poll.ID.String(), poll)
+	}
+	return nil
+}
+
+// createPoll stores the poll at version 1. The caller must hold s.mu.
+func (s *Repository) createPoll(pollID string, poll models.Poll) {
+	votes := make(map[string]int, len(poll.Votes))
+	for option, count := range poll.Votes {
+		votes[option] = count
+	}
+
+	poll.Version = 1
+	s.polls[pollID] = &entry{
+		poll:    copyPoll(poll),
+		votes:   votes,
+		ballots: make(map[string][]string),
+	}
+}
+
+func (s *Repository) GetPoll(ctx context.Context, pollID string) (*models.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.polls[pollID]
+	if !ok {
+		return nil, fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+	}
+
+	poll := e.snapshot()
+	return &poll, nil
+}
+
+func (s *Repository) ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error) {
+	after, err := repo.DecodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := repo.QueryTerms(query.Search)
+	var matches []*entry
+	for _, e := range s.polls {
+		if e.matches(query, terms) {
+			matches = append(matches, e)
+		}
+	}
+
+	// Polls sharing a score are ordered by ID in the listing's direction,
+	// like the Redis sorted set indexes.
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].score(query.Sort), matches[j].score(query.Sort)
+		if a != b {
+			return (a < b) == query.Ascending
+		}
+		return (matches[i].poll.ID.String() < matches[j].poll.ID.String()) == query.Ascending
+	})
+
+	page := &models.PollPage{Polls: make([]models.Poll, 0, limit)}
+	var last *entry
+	for _, e := range matches {
+		if after != nil && !e.follows(*after, query) {
+			continue
+		}
+
+		if len(page.Polls) == limit {
+			page.NextCursor = repo.Cursor{
+				Score:  last.score(query.Sort),
+				Member: last.poll.ID.String(),
+			}.Encode()
+			break
+		}
+		page.Polls = append(page.Polls, e.snapshot())
+		last = e
+	}
+
+	return page, nil
+}
+
+func (s *Repository) DeletePoll(ctx context.Context, pollID string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if version != 0 {
+		e, ok := s.polls[pollID]
+		if !ok {
+			return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+		} else if e.poll.Version != version {
+			return fmt.Errorf("%w: poll %s is at version %d", repo.ErrVersionConflict, pollID, e.poll.Version)
+		}
+	}
+
+	delete(s.polls, pollID)
+	delete(s.events, pollID)
+	return nil
+}
+
+func (s *Repository) UpdatePoll(ctx context.Context, pollID string, poll models.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.polls[pollID]
+	if !ok {
+		return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+	} else if e.poll.Version != poll.Version {
+		return fmt.Errorf("%w: poll %s is at version %d", repo.ErrVersionConflict, pollID, e.poll.Version)
+	}
+
+	e.poll = copyPoll(poll)
+	e.poll.Version++
+
+	// Counts of removed options go with them.
+	kept := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		kept[option.ID] = true
+	}
+	for option := range e.votes {
+		if !kept[option] {
+			delete(e.votes, option)
+		}
+	}
+	return nil
+}
+
+func (s *Repository) RecordVote(ctx context.Context, pollID string, ballot models.Ballot, allowChange bool, event models.VoteEvent) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.polls[pollID]
+	if !ok {
+		return nil, fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+	}
+
+	if previous, voted := e.ballots[ballot.UserID]; voted {
+		if !allowChange {
+			return nil, repo.ErrAlreadyVoted
+		}
+		previousBallot := models.Ballot{Choices: previous, Ranked: ballot.Ranked}
+		for _, option := range previousBallot.Counted() {
+			e.votes[option]--
+		}
+	}
+
+	e.ballots[ballot.UserID] = append([]string(nil), ballot.Choices...)
+	for _, option := range ballot.Counted() {
+		e.votes[option]++
+	}
+	s.appendVoteEvent(event)
+
+	return copyVotes(e.votes), nil
+}
+
+func (s *Repository) ListBallots(ctx context.Context, pollID string) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ballots := make(map[string][]string)
+	if e, ok := s.polls[pollID]; ok {
+		for userID, choices := range e.ballots {
+			ballots[userID] = append([]string(nil), choices...)
+		}
+	}
+	return ballots, nil
+}
+
+func (s *Repository) HasVoted(ctx context.Context, pollID, userID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.polls[pollID]
+	if !ok {
+		return false, nil
+	}
+	_, voted := e.ballots[userID]
+	return voted, nil
+}
+
+func (s *Repository) ReplaceVotes(ctx context.Context, pollID string, votes map[string]int, ballots map[string][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.polls[pollID]
+	if !ok {
+		return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+	}
+
+	e.votes = copyVotes(votes)
+	e.ballots = make(map[string][]string, len(ballots))
+	for userID, choices := range ballots {
+		e.ballots[userID] = append([]string(nil), choices...)
+	}
+	return nil
+}
+
+func (s *Repository) DueScheduledPolls(ctx context.Context, now time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pollIDs []string
+	for pollID, e := range s.polls {
+		if at, ok := e.poll.NextTransition(); ok && !at.After(now) {
+			pollIDs = append(pollIDs, pollID)
+		}
+	}
+	return pollIDs, nil
+}
+
+func (s *Repository) AppendVoteEvent(ctx context.Context, event models.VoteEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appendVoteEvent(event)
+	return nil
+}
+
+// appendVoteEvent appends the event to its poll's log. The caller must hold
+// the write lock.
+func (s *Repository) appendVoteEvent(event models.VoteEvent) {
+	s.nextID++
+	event.ID = strconv.FormatInt(s.nextID, 10)
+	event.Options = append([]string(nil), event.Options...)
+	s.events[event.PollID] = append(s.events[event.PollID], event)
+}
+
+func (s *Repository) ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]models.VoteEvent, len(s.events[pollID]))
+	copy(events, s.events[pollID])
+	return events, nil
+}
+
+// snapshot returns a copy of the poll with its votes that is safe to hand
+// out after the lock is released.
+func (e *entry) snapshot() models.Poll {
+	poll := copyPoll(e.poll)
+	poll.Votes = copyVotes(e.votes)
+	return poll
+}
+
+// score is the poll's position in the listing: its creation time in Unix
+// milliseconds, or its total votes, the sum of its option counts.
+func (e *entry) score(sortBy models.PollSort) float64 {
+	if sortBy == models.SortVotes {
+		total := 0
+		for _, count := range e.votes {
+			total += count
+		}
+		return float64(total)
+	}
+	return float64(e.poll.CreatedAt.UnixMilli())
+}
+
+// follows reports whether the poll is listed after the cursor.
+func (e *entry) follows(after repo.Cursor, query models.PollQuery) bool {
+	score := e.score(query.Sort)
+	if score == after.Score {
+		return after.After(e.poll.ID.String(), query.Ascending)
+	}
+	return (score > after.Score) == query.Ascending
+}
+
+func (e *entry) matches(query models.PollQuery, terms []string) bool {
+	poll := e.poll
+	if query.Status != "" && poll.Status != query.Status {
+		return false
+	}
+	if query.CreatedBy != "" && poll.CreatedBy != query.CreatedBy {
+		return false
+	}
+	created := poll.CreatedAt.UnixMilli()
+	if query.CreatedAfter != nil && created < query.CreatedAfter.UnixMilli() {
+		return false
+	}
+	if query.CreatedBefore != nil && created >= query.CreatedBefore.UnixMilli() {
+		return false
+	}
+	if !containsAll(poll.Tags, query.Tags) {
+		return false
+	}
+	return len(terms) == 0 || containsAll(repo.SearchTerms(poll), terms)
+}
+
+func containsAll(values, wanted []string) bool {
+	present := make(map[string]bool, len(values))
+	for _, value := range values {
+		present[value] = true
+	}
+	for _, value := range wanted {
+		if !present[value] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyVotes(votes map[string]int) map[string]int {
+	copied := make(map[string]int, len(votes))
+	for option, count := range votes {
+		copied[option] = count
+	}
+	return copied
+}
+
+// copyPoll copies the poll deep enough that the caller and the repository do
+// not share slices or times. Votes are kept separately and are dropped.
+func copyPoll(poll models.Poll) models.Poll {
+	poll.Votes = nil
+	poll.Options = append([]models.Option(nil), poll.Options...)
+	poll.Tags = append([]string(nil), poll.Tags...)
+	if poll.OpensAt != nil {
+		opensAt := *poll.OpensAt
+		poll.OpensAt = &opensAt
+	}
+	if poll.ClosesAt != nil {
+		closesAt := *poll.ClosesAt
+		poll.ClosesAt = &closesAt
+	}
+	return poll
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"poll/repo"
+	"poll/repo/repotest"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		return New()
+	})
+}
@@ -0,0 +1,85 @@
+CREATE TABLE polls (
+    id                UUID PRIMARY KEY,
+    question          TEXT        NOT NULL,
+    allow_vote_change BOOLEAN     NOT NULL DEFAULT FALSE,
+    status            TEXT        NOT NULL,
+    opens_at          TIMESTAMPTZ,
+    closes_at         TIMESTAMPTZ,
+    ballot_type       TEXT        NOT NULL,
+    min_choices       INTEGER     NOT NULL DEFAULT 0,
+    max_choices       INTEGER     NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL,
+    created_by        TEXT        NOT NULL DEFAULT '',
+    -- Number of users with a ballot, kept up to date for sorting by votes.
+    voters            INTEGER     NOT NULL DEFAULT 0
+);
+
+CREATE INDEX polls_created_idx ON polls (created_at, id);
+CREATE INDEX polls_voters_idx ON polls (voters, id);
+CREATE INDEX polls_status_idx ON polls (status);
+CREATE INDEX polls_created_by_idx ON polls (created_by);
+CREATE INDEX polls_opens_at_idx ON polls (opens_at) WHERE status = 'draft';
+CREATE INDEX polls_closes_at_idx ON polls (closes_at) WHERE status = 'open';
+
+CREATE TABLE poll_options (
+    poll_id     UUID    NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    id          TEXT    NOT NULL,
+    label       TEXT    NOT NULL,
+    description TEXT    NOT NULL DEFAULT '',
+    image_url   TEXT    NOT NULL DEFAULT '',
+    position    INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, id)
+);
+
+CREATE TABLE poll_tags (
+    poll_id UUID NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    tag     TEXT NOT NULL,
+    PRIMARY KEY (poll_id, tag)
+);
+
+CREATE INDEX poll_tags_tag_idx ON poll_tags (tag, poll_id);
+
+-- Word prefixes of the question and options, see repo.SearchTerms.
+CREATE TABLE poll_terms (
+    poll_id UUID NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    term    TEXT NOT NULL,
+    PRIMARY KEY (poll_id, term)
+);
+
+CREATE INDEX poll_terms_term_idx ON poll_terms (term, poll_id);
+
+CREATE TABLE vote_counts (
+    poll_id   UUID    NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    option_id TEXT    NOT NULL,
+    count     INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, option_id)
+);
+
+CREATE TABLE ballots (
+    poll_id UUID        NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    user_id TEXT        NOT NULL,
+    cast_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (poll_id, user_id)
+);
+
+CREATE TABLE ballot_choices (
+    poll_id   UUID    NOT NULL,
+    user_id   TEXT    NOT NULL,
+    position  INTEGER NOT NULL,
+    option_id TEXT    NOT NULL,
+    PRIMARY KEY (poll_id, user_id, position),
+    FOREIGN KEY (poll_id, user_id) REFERENCES ballots (poll_id, user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE vote_events (
+    id         BIGSERIAL PRIMARY KEY,
+    poll_id    UUID        NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    user_id    TEXT        NOT NULL,
+    option_id  TEXT        NOT NULL DEFAULT '',
+    options    TEXT[]      NOT NULL DEFAULT '{}',
+    cast_at    TIMESTAMPTZ NOT NULL,
+    client_ip  TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX vote_events_poll_idx ON vote_events (poll_id, id);
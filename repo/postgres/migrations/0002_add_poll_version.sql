@@ -0,0 +1,2 @@
+-- Counts the writes of a poll's details, for optimistic concurrency.
+ALTER TABLE polls ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Who may see a poll's vote counts, see models.ResultsVisibility.
+ALTER TABLE polls ADD COLUMN results_visibility TEXT NOT NULL DEFAULT 'always';
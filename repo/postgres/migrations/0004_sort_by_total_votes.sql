@@ -0,0 +1,5 @@
+-- Listings sort by total votes, the sum of the option counts, rather than by
+-- the number of users with a ballot, which differ for multi-choice polls.
+ALTER TABLE polls RENAME COLUMN voters TO total_votes;
+ALTER INDEX polls_voters_idx RENAME TO polls_total_votes_idx;
+UPDATE polls p SET total_votes = COALESCE((SELECT SUM(c.count) FROM vote_counts c WHERE c.poll_id = p.id), 0);
@@ -0,0 +1,893 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"poll/configs"
+	"poll/models"
+	"poll/repo"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPageSize = 20
+
+// Repository stores polls in PostgreSQL. Polls, their options, tags and
+// search terms, vote counts, ballots and the vote event log live in
+// normalized tables, see the migrations directory.
+type Repository struct {
+	db  *sql.DB
+	cfg configs.RepoConfig
+}
+
+func New(ctx context.Context, cfg configs.RepoConfig) (*Repository, error) {
+	db, err := sql.Open("postgres", cfg.Postgres.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL connection: %v", err)
+	}
+	db.SetMaxOpenConns(cfg.Postgres.MaxOpenConns)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %v", err)
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate PostgreSQL schema: %v", err)
+	}
+
+	return &Repository{
+		db:  db,
+		cfg: cfg,
+	}, nil
+}
+
+func (s *Repository) Close() error {
+	return s.db.Close()
+}
+
+// withTx runs fn in a transaction, committing it if fn succeeds.
+func (s *Repository) withTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// validID reports whether the poll ID can exist at all; anything else would
+// make PostgreSQL reject the query instead of finding nothing.
+func validID(pollID string) bool {
+	_, err := uuid.Parse(pollID)
+	return err == nil
+}
+
+func notFound(pollID string) error {
+	return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+}
+
+func (s *Repository) CreatePoll(ctx context.Context, pollID string, poll models.Poll) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		return insertPoll(ctxWithTimeout, tx, pollID, poll)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save poll %s: %w", pollID, err)
+	}
+
+	return nil
+}
+
+func (s *Repository) CreatePolls(ctx context.Context, polls []models.Poll) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		for _, poll := range polls {
+			if err := insertPoll(ctxWithTimeout, tx, poll.ID.String(), poll); err != nil {
+				return fmt.Errorf("poll %s: %w", poll.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %d polls: %w", len(polls), err)
+	}
+
+	return nil
+}
+
+// insertPoll stores the poll with its vote counts and details.
+func insertPoll(ctx context.Context, tx *sql.Tx, pollID string, poll models.Poll) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO polls (id, question, allow_vote_change, status, opens_at, closes_at,
+			ballot_type, min_choices, max_choices, created_at, created_by, results_visibility, total_votes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		pollID, poll.Question, poll.AllowVoteChange, poll.Status, poll.OpensAt, poll.ClosesAt,
+		poll.BallotType, poll.MinChoices, poll.MaxChoices, poll.CreatedAt, poll.CreatedBy, poll.ResultsVisibility,
+		totalVotes(poll.Votes))
+	if err != nil {
+		return err
+	}
+
+	for option, count := range poll.Votes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO vote_counts (poll_id, option_id, count) VALUES ($1, $2, $3)`, pollID, option, count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return insertDetails(ctx, tx, pollID, poll)
+}
+
+// insertDetails stores the options, tags and search terms of the poll.
+func insertDetails(ctx context.Context, tx *sql.Tx, pollID string, poll models.Poll) error {
+	for _, option := range poll.Options {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO poll_options (poll_id, id, label, description, image_url, position)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			pollID, option.ID, option.Label, option.Description, option.ImageURL, option.Order)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range poll.Tags {
+		_, err := tx.ExecContext(ctx, `INSERT INTO poll_tags (poll_id, tag) VALUES ($1, $2)`, pollID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO poll_terms (poll_id, term) SELECT $1::uuid, unnest($2::text[])`,
+		pollID, pq.Array(repo.SearchTerms(poll)))
+	return err
+}
+
+func (s *Repository) GetPoll(ctx context.Context, pollID string) (*models.Poll, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	polls, err := s.getPolls(ctxWithTimeout, []string{pollID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll %s: %w", pollID, err)
+	}
+	if len(polls) == 0 {
+		return nil, notFound(pollID)
+	}
+
+	return &polls[0], nil
+}
+
+// getPolls loads the polls in the given order from a single snapshot. Polls
+// that do not exist are skipped.
+func (s *Repository) getPolls(ctx context.Context, pollIDs []string) ([]models.Poll, error) {
+	var valid []string
+	for _, pollID := range pollIDs {
+		if id, err := uuid.Parse(pollID); err == nil {
+			valid = append(valid, id.String())
+		}
+	}
+	if len(valid) == 0 {
+		return []models.Poll{}, nil
+	}
+
+	byID := make(map[string]*models.Poll, len(valid))
+	ids := pq.Array(valid)
+	err := s.withTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, question, allow_vote_change, status, opens_at, closes_at,
+				ballot_type, min_choices, max_choices, created_at, created_by, results_visibility, version
+			FROM polls WHERE id = ANY($1::uuid[])`, ids)
+		if err != nil {
+			return err
+		}
+		err = scanRows(rows, func() error {
+			var poll models.Poll
+			var opensAt, closesAt sql.NullTime
+			err := rows.Scan(&poll.ID, &poll.Question, &poll.AllowVoteChange, &poll.Status, &opensAt, &closesAt,
+				&poll.BallotType, &poll.MinChoices, &poll.MaxChoices, &poll.CreatedAt, &poll.CreatedBy,
+				&poll.ResultsVisibility, &poll.Version)
+			if err != nil {
+				return err
+			}
+			poll.OpensAt = nullTime(opensAt)
+			poll.ClosesAt = nullTime(closesAt)
+			poll.CreatedAt = poll.CreatedAt.UTC()
+			poll.Votes = make(map[string]int)
+			byID[poll.ID.String()] = &poll
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+			SELECT poll_id, id, label, description, image_url, position
+			FROM poll_options WHERE poll_id = ANY($1::uuid[]) ORDER BY poll_id, position`, ids)
+		if err != nil {
+			return err
+		}
+		err = scanRows(rows, func() error {
+			var pollID string
+			var option models.Option
+			if err := rows.Scan(&pollID, &option.ID, &option.Label, &option.Description, &option.ImageURL, &option.Order); err != nil {
+				return err
+			}
+			if poll, ok := byID[pollID]; ok {
+				poll.Options = append(poll.Options, option)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+			SELECT poll_id, tag FROM poll_tags WHERE poll_id = ANY($1::uuid[]) ORDER BY poll_id, tag`, ids)
+		if err != nil {
+			return err
+		}
+		err = scanRows(rows, func() error {
+			var pollID, tag string
+			if err := rows.Scan(&pollID, &tag); err != nil {
+				return err
+			}
+			if poll, ok := byID[pollID]; ok {
+				poll.Tags = append(poll.Tags, tag)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+			SELECT poll_id, option_id, count FROM vote_counts WHERE poll_id = ANY($1::uuid[])`, ids)
+		if err != nil {
+			return err
+		}
+		return scanRows(rows, func() error {
+			var pollID, option string
+			var count int
+			if err := rows.Scan(&pollID, &option, &count); err != nil {
+				return err
+			}
+			if poll, ok := byID[pollID]; ok {
+				poll.Votes[option] = count
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	polls := make([]models.Poll, 0, len(byID))
+	for _, pollID := range valid {
+		if poll, ok := byID[pollID]; ok {
+			polls = append(polls, *poll)
+		}
+	}
+	return polls, nil
+}
+
+// scanRows calls scan for every row and closes the rows.
+func scanRows(rows *sql.Rows, scan func() error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	value := t.Time.UTC()
+	return &value
+}
+
+func (s *Repository) ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	after, err := repo.DecodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil && !validID(after.Member) {
+		return nil, repo.ErrInvalidCursor
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	statement, args := selectPolls(query, after, limit+1)
+	rows, err := s.db.QueryContext(ctxWithTimeout, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %w", err)
+	}
+
+	var pollIDs []string
+	var scores []float64
+	err = scanRows(rows, func() error {
+		var pollID string
+		var createdAt time.Time
+		var totalVotes int
+		if err := rows.Scan(&pollID, &createdAt, &totalVotes); err != nil {
+			return err
+		}
+		pollIDs = append(pollIDs, pollID)
+		if query.Sort == models.SortVotes {
+			scores = append(scores, float64(totalVotes))
+		} else {
+			scores = append(scores, float64(createdAt.UnixMicro()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %w", err)
+	}
+
+	more := len(pollIDs) > limit
+	if more {
+		pollIDs, scores = pollIDs[:limit], scores[:limit]
+	}
+
+	polls, err := s.getPolls(ctxWithTimeout, pollIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %w", err)
+	}
+
+	page := &models.PollPage{Polls: polls}
+	if more {
+		page.NextCursor = repo.Cursor{Score: scores[len(scores)-1], Member: pollIDs[len(pollIDs)-1]}.Encode()
+	}
+
+	return page, nil
+}
+
+// selectPolls builds the query listing the IDs of the polls matching the
+// query, in order, starting after the cursor. Created-time cursors hold
+// microseconds since the epoch, the precision of PostgreSQL timestamps.
+func selectPolls(query models.PollQuery, after *repo.Cursor, limit int) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if query.Status != "" {
+		conditions = append(conditions, "p.status = "+arg(query.Status))
+	}
+	if query.CreatedBy != "" {
+		conditions = append(conditions, "p.created_by = "+arg(query.CreatedBy))
+	}
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "p.created_at >= "+arg(*query.CreatedAfter))
+	}
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, "p.created_at < "+arg(*query.CreatedBefore))
+	}
+	for _, tag := range query.Tags {
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM poll_tags t WHERE t.poll_id = p.id AND t.tag = "+arg(tag)+")")
+	}
+	for _, term := range repo.QueryTerms(query.Search) {
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM poll_terms t WHERE t.poll_id = p.id AND t.term = "+arg(term)+")")
+	}
+
+	column := "p.created_at"
+	if query.Sort == models.SortVotes {
+		column = "p.total_votes"
+	}
+	direction, comparison := "DESC", "<"
+	if query.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if after != nil {
+		var score interface{} = int64(after.Score)
+		if query.Sort != models.SortVotes {
+			score = time.UnixMicro(int64(after.Score)).UTC()
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, p.id) %s (%s, %s::uuid)",
+			column, comparison, arg(score), arg(after.Member)))
+	}
+
+	statement := "SELECT p.id, p.created_at, p.total_votes FROM polls p"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += fmt.Sprintf(" ORDER BY %s %s, p.id %s LIMIT %s", column, direction, direction, arg(limit))
+
+	return statement, args
+}
+
+func (s *Repository) DeletePoll(ctx context.Context, pollID string, version int64) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	if !validID(pollID) {
+		if version != 0 {
+			return notFound(pollID)
+		}
+		return nil
+	}
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctxWithTimeout,
+			`DELETE FROM polls WHERE id = $1 AND ($2::bigint = 0 OR version = $2)`, pollID, version)
+		if err != nil {
+			return err
+		}
+		if deleted, err := res.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 && version != 0 {
+			return versionMismatch(ctxWithTimeout, tx, pollID)
+		}
+		return nil
+	})
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrVersionConflict) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to delete poll %s: %w", pollID, err)
+	}
+
+	return nil
+}
+
+// versionMismatch explains why a write guarded by the poll's version did
+// not touch any row: either the poll is gone or it is at another version.
+func versionMismatch(ctx context.Context, tx *sql.Tx, pollID string) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, `SELECT version FROM polls WHERE id = $1`, pollID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return notFound(pollID)
+	} else if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: poll %s is at version %d", repo.ErrVersionConflict, pollID, version)
+}
+
+func (s *Repository) UpdatePoll(ctx context.Context, pollID string, poll models.Poll) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	if !validID(pollID) {
+		return notFound(pollID)
+	}
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctxWithTimeout, `
+			UPDATE polls SET question = $2, allow_vote_change = $3, status = $4, opens_at = $5,
+				closes_at = $6, ballot_type = $7, min_choices = $8, max_choices = $9,
+				created_at = $10, created_by = $11, results_visibility = $13, version = version + 1
+			WHERE id = $1 AND version = $12`,
+			pollID, poll.Question, poll.AllowVoteChange, poll.Status, poll.OpensAt, poll.ClosesAt,
+			poll.BallotType, poll.MinChoices, poll.MaxChoices, poll.CreatedAt, poll.CreatedBy, poll.Version,
+			poll.ResultsVisibility)
+		if err != nil {
+			return err
+		}
+		if updated, err := res.RowsAffected(); err != nil {
+			return err
+		} else if updated == 0 {
+			return versionMismatch(ctxWithTimeout, tx, pollID)
+		}
+
+		for _, table := range []string{"poll_options", "poll_tags", "poll_terms"} {
+			if _, err := tx.ExecContext(ctxWithTimeout, `DELETE FROM `+table+` WHERE poll_id = $1`, pollID); err != nil {
+				return err
+			}
+		}
+
+		// Counts of removed options go with them, and out of the total.
+		if _, err := tx.ExecContext(ctxWithTimeout, `
+			WITH removed AS (
+				DELETE FROM vote_counts WHERE poll_id = $1 AND NOT (option_id = ANY($2))
+				RETURNING count
+			)
+			UPDATE polls SET total_votes = total_votes - (SELECT COALESCE(SUM(count), 0) FROM removed)
+			WHERE id = $1`,
+			pollID, pq.Array(models.OptionIDs(poll.Options))); err != nil {
+			return err
+		}
+
+		return insertDetails(ctxWithTimeout, tx, pollID, poll)
+	})
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrVersionConflict) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to update poll %s: %w", pollID, err)
+	}
+
+	return nil
+}
+
+func (s *Repository) RecordVote(ctx context.Context, pollID string, ballot models.Ballot, allowChange bool, event models.VoteEvent) (map[string]int, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	if !validID(pollID) {
+		return nil, notFound(pollID)
+	}
+
+	var votes map[string]int
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		// Inserting the ballot first, rather than reading it, makes
+		// concurrent votes of the same user wait for each other. Its foreign
+		// key also keeps the poll from being deleted until the vote is done.
+		_, err := tx.ExecContext(ctxWithTimeout, `
+			INSERT INTO ballots (poll_id, user_id) VALUES ($1, $2)
+			ON CONFLICT (poll_id, user_id) DO UPDATE SET cast_at = now()`, pollID, ballot.UserID)
+		if isForeignKeyViolation(err) {
+			return notFound(pollID)
+		} else if err != nil {
+			return err
+		}
+
+		previous, err := ballotChoices(ctxWithTimeout, tx, pollID, ballot.UserID)
+		if err != nil {
+			return err
+		}
+
+		if len(previous) > 0 && !allowChange {
+			return repo.ErrAlreadyVoted
+		}
+
+		if err := insertVoteEvent(ctxWithTimeout, tx, event); err != nil {
+			return err
+		}
+		if strings.Join(previous, "\x00") == strings.Join(ballot.Choices, "\x00") {
+			votes, err = voteCounts(ctxWithTimeout, tx, pollID)
+			return err
+		}
+
+		delta := make(map[string]int)
+		for _, option := range (models.Ballot{Choices: previous, Ranked: ballot.Ranked}).Counted() {
+			delta[option]--
+		}
+		for _, option := range ballot.Counted() {
+			delta[option]++
+		}
+		if err := applyDelta(ctxWithTimeout, tx, pollID, delta); err != nil {
+			return err
+		}
+
+		if err := replaceChoices(ctxWithTimeout, tx, pollID, ballot.UserID, ballot.Choices); err != nil {
+			return err
+		}
+
+		votes, err = voteCounts(ctxWithTimeout, tx, pollID)
+		return err
+	})
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrAlreadyVoted) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to record vote for poll %s: %w", pollID, err)
+	}
+
+	return votes, nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func ballotChoices(ctx context.Context, tx *sql.Tx, pollID, userID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT option_id FROM ballot_choices WHERE poll_id = $1 AND user_id = $2 ORDER BY position`,
+		pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []string
+	err = scanRows(rows, func() error {
+		var option string
+		if err := rows.Scan(&option); err != nil {
+			return err
+		}
+		choices = append(choices, option)
+		return nil
+	})
+	return choices, err
+}
+
+func replaceChoices(ctx context.Context, tx *sql.Tx, pollID, userID string, choices []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM ballot_choices WHERE poll_id = $1 AND user_id = $2`, pollID, userID)
+	if err != nil {
+		return err
+	}
+
+	for position, option := range choices {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO ballot_choices (poll_id, user_id, position, option_id) VALUES ($1, $2, $3, $4)`,
+			pollID, userID, position, option)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDelta adjusts the vote counts of the options and the poll's total
+// votes. Options are updated in a fixed order so concurrent votes lock the
+// counts in the same order.
+func applyDelta(ctx context.Context, tx *sql.Tx, pollID string, delta map[string]int) error {
+	options := make([]string, 0, len(delta))
+	for option, change := range delta {
+		if change != 0 {
+			options = append(options, option)
+		}
+	}
+	sort.Strings(options)
+
+	for _, option := range options {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO vote_counts (poll_id, option_id, count) VALUES ($1, $2, $3)
+			ON CONFLICT (poll_id, option_id) DO UPDATE SET count = vote_counts.count + EXCLUDED.count`,
+			pollID, option, delta[option])
+		if err != nil {
+			return err
+		}
+	}
+
+	if total := totalVotes(delta); total != 0 {
+		_, err := tx.ExecContext(ctx, `UPDATE polls SET total_votes = total_votes + $2 WHERE id = $1`, pollID, total)
+		return err
+	}
+	return nil
+}
+
+// totalVotes sums the vote counts of all options, which listings sort by.
+func totalVotes(votes map[string]int) int {
+	total := 0
+	for _, count := range votes {
+		total += count
+	}
+	return total
+}
+
+func voteCounts(ctx context.Context, tx *sql.Tx, pollID string) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT option_id, count FROM vote_counts WHERE poll_id = $1`, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[string]int)
+	err = scanRows(rows, func() error {
+		var option string
+		var count int
+		if err := rows.Scan(&option, &count); err != nil {
+			return err
+		}
+		votes[option] = count
+		return nil
+	})
+	return votes, err
+}
+
+func (s *Repository) ListBallots(ctx context.Context, pollID string) (map[string][]string, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	ballots := make(map[string][]string)
+	if !validID(pollID) {
+		return ballots, nil
+	}
+
+	rows, err := s.db.QueryContext(ctxWithTimeout, `
+		SELECT user_id, option_id FROM ballot_choices WHERE poll_id = $1 ORDER BY user_id, position`, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ballots for poll %s: %w", pollID, err)
+	}
+
+	err = scanRows(rows, func() error {
+		var userID, option string
+		if err := rows.Scan(&userID, &option); err != nil {
+			return err
+		}
+		ballots[userID] = append(ballots[userID], option)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ballots for poll %s: %w", pollID, err)
+	}
+
+	return ballots, nil
+}
+
+func (s *Repository) HasVoted(ctx context.Context, pollID, userID string) (bool, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	if !validID(pollID) {
+		return false, nil
+	}
+
+	var voted bool
+	err := s.db.QueryRowContext(ctxWithTimeout, `
+		SELECT EXISTS (SELECT 1 FROM ballots WHERE poll_id = $1 AND user_id = $2)`, pollID, userID).Scan(&voted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check ballot of user %s in poll %s: %w", userID, pollID, err)
+	}
+
+	return voted, nil
+}
+
+func (s *Repository) ReplaceVotes(ctx context.Context, pollID string, votes map[string]int, ballots map[string][]string) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	if !validID(pollID) {
+		return notFound(pollID)
+	}
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctxWithTimeout, `UPDATE polls SET total_votes = $2 WHERE id = $1`, pollID, totalVotes(votes))
+		if err != nil {
+			return err
+		}
+		if updated, err := res.RowsAffected(); err != nil {
+			return err
+		} else if updated == 0 {
+			return notFound(pollID)
+		}
+
+		for _, table := range []string{"vote_counts", "ballots"} {
+			if _, err := tx.ExecContext(ctxWithTimeout, `DELETE FROM `+table+` WHERE poll_id = $1`, pollID); err != nil {
+				return err
+			}
+		}
+
+		for option, count := range votes {
+			_, err := tx.ExecContext(ctxWithTimeout,
+				`INSERT INTO vote_counts (poll_id, option_id, count) VALUES ($1, $2, $3)`, pollID, option, count)
+			if err != nil {
+				return err
+			}
+		}
+
+		for userID, choices := range ballots {
+			_, err := tx.ExecContext(ctxWithTimeout,
+				`INSERT INTO ballots (poll_id, user_id) VALUES ($1, $2)`, pollID, userID)
+			if err != nil {
+				return err
+			}
+			if err := replaceChoices(ctxWithTimeout, tx, pollID, userID, choices); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, repo.ErrNotFound) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to replace votes for poll %s: %w", pollID, err)
+	}
+
+	return nil
+}
+
+func (s *Repository) DueScheduledPolls(ctx context.Context, now time.Time) ([]string, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctxWithTimeout, `
+		SELECT id FROM polls WHERE status = 'draft' AND opens_at <= $1
+		UNION ALL
+		SELECT id FROM polls WHERE status = 'open' AND closes_at <= $1`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled polls: %w", err)
+	}
+
+	var pollIDs []string
+	err = scanRows(rows, func() error {
+		var pollID string
+		if err := rows.Scan(&pollID); err != nil {
+			return err
+		}
+		pollIDs = append(pollIDs, pollID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled polls: %w", err)
+	}
+
+	return pollIDs, nil
+}
+
+func (s *Repository) AppendVoteEvent(ctx context.Context, event models.VoteEvent) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	err := s.withTx(ctxWithTimeout, nil, func(tx *sql.Tx) error {
+		return insertVoteEvent(ctxWithTimeout, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append vote event for poll %s: %w", event.PollID, err)
+	}
+
+	return nil
+}
+
+func insertVoteEvent(ctx context.Context, tx *sql.Tx, event models.VoteEvent) error {
+	options := event.Options
+	if options == nil {
+		options = []string{}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO vote_events (poll_id, user_id, option_id, options, cast_at, client_ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.PollID, event.UserID, event.Option, pq.Array(options), event.Timestamp, event.ClientIP, event.UserAgent)
+	return err
+}
+
+func (s *Repository) ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	events := make([]models.VoteEvent, 0)
+	if !validID(pollID) {
+		return events, nil
+	}
+
+	rows, err := s.db.QueryContext(ctxWithTimeout, `
+		SELECT id, poll_id, user_id, option_id, options, cast_at, client_ip, user_agent
+		FROM vote_events WHERE poll_id = $1 ORDER BY id`, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vote events for poll %s: %w", pollID, err)
+	}
+
+	err = scanRows(rows, func() error {
+		var event models.VoteEvent
+		var id int64
+		var options []string
+		err := rows.Scan(&id, &event.PollID, &event.UserID, &event.Option, pq.Array(&options),
+			&event.Timestamp, &event.ClientIP, &event.UserAgent)
+		if err != nil {
+			return err
+		}
+		event.ID = strconv.FormatInt(id, 10)
+		event.Timestamp = event.Timestamp.UTC()
+		if len(options) > 0 {
+			event.Options = options
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vote events for poll %s: %w", pollID, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"poll/configs"
+	"poll/repo"
+	"poll/repo/repotest"
+	"testing"
+	"time"
+)
+
+// TestRepository runs the conformance suite against the PostgreSQL server
+// POSTGRES_DSN names, as a URL of a user allowed to create databases, else
+// against an embedded server. It is skipped when no embedded server can be
+// started, but fails when the server POSTGRES_DSN names cannot be reached, so
+// a run meant to cover PostgreSQL never passes without doing so.
+func TestRepository(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		dsn = startEmbedded(t)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open PostgreSQL connection: %v", err)
+	}
+	defer admin.Close()
+	if err := admin.Ping(); err != nil {
+		t.Fatalf("PostgreSQL is not available: %v", err)
+	}
+
+	n := 0
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		n++
+		return newTestRepo(t, admin, dsn, fmt.Sprintf("poll_test_%d_%d", os.Getpid(), n))
+	})
+}
+
+// startEmbedded starts a PostgreSQL server for the test and returns its URL,
+// skipping the test if it cannot be started, such as when its binaries
+// cannot be downloaded.
+func startEmbedded(t *testing.T) string {
+	if testing.Short() {
+		t.Skip("set POSTGRES_DSN to test against PostgreSQL in short mode")
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Skipf("no port for embedded PostgreSQL: %v", err)
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("poll").
+		RuntimePath(t.TempDir()).
+		Logger(io.Discard)
+	db := embeddedpostgres.NewDatabase(cfg)
+	if err := db.Start(); err != nil {
+		t.Skipf("embedded PostgreSQL is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Stop(); err != nil {
+			t.Errorf("failed to stop embedded PostgreSQL: %v", err)
+		}
+	})
+
+	return cfg.GetConnectionURL() + "?sslmode=disable"
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// newTestRepo creates the database name on the server and returns a
+// repository migrated into it. The database is dropped after the test.
+func newTestRepo(t *testing.T, admin *sql.DB, dsn, name string) *Repository {
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create database %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + name); err != nil {
+			t.Errorf("failed to drop database %s: %v", name, err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("POSTGRES_DSN must be a URL: %v", err)
+	}
+	u.Path = "/" + name
+
+	r, err := New(context.Background(), configs.RepoConfig{
+		Postgres: configs.PostgresConfig{DSN: u.String(), MaxOpenConns: 4},
+		Timeout:  configs.Duration{Duration: 10 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	return r
+}
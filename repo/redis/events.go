@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"poll/models"
+	"time"
+)
+
+const eventsBatchSize = 1000
+
+func (s *RedisRepo) generateEventsKey(pollID string) string {
+	return fmt.Sprintf("%s:%s:events", appID, pollID)
+}
+
+func (s *RedisRepo) AppendVoteEvent(ctx context.Context, event models.VoteEvent) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	values, err := voteEventValues(event)
+	if err != nil {
+		return err
+	}
+
+	err = s.client.XAdd(ctxWithTimeout, &redis.XAddArgs{
+		Stream: s.generateEventsKey(event.PollID),
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to append vote event for poll %s: %w", event.PollID, err)
+	}
+
+	return nil
+}
+
+// voteEventValues returns the fields and values of the event's stream entry,
+// see parseVoteEvent.
+func voteEventValues(event models.VoteEvent) ([]interface{}, error) {
+	options, err := json.Marshal(event.Options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vote event options: %w", err)
+	}
+
+	return []interface{}{
+		"poll_id", event.PollID,
+		"user_id", event.UserID,
+		"option", event.Option,
+		"options", options,
+		"timestamp", event.Timestamp.UTC().Format(time.RFC3339Nano),
+		"client_ip", event.ClientIP,
+		"user_agent", event.UserAgent,
+	}, nil
+}
+
+// ListVoteEvents returns all events of the poll in the order they were
+// appended. The stream is read in batches to keep single replies small.
+func (s *RedisRepo) ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	key := s.generateEventsKey(pollID)
+	events := make([]models.VoteEvent, 0)
+	start := "-"
+	for {
+		messages, err := s.client.XRangeN(ctxWithTimeout, key, start, "+", eventsBatchSize).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vote events for poll %s: %w", pollID, err)
+		}
+
+		for _, msg := range messages {
+			event, err := parseVoteEvent(msg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse vote event %s: %w", msg.ID, err)
+			}
+			events = append(events, event)
+		}
+
+		if len(messages) < eventsBatchSize {
+			return events, nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+func parseVoteEvent(msg redis.XMessage) (models.VoteEvent, error) {
+	field := func(name string) string {
+		value, _ := msg.Values[name].(string)
+		return value
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, field("timestamp"))
+	if err != nil {
+		return models.VoteEvent{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	var options []string
+	if raw := field("options"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &options); err != nil {
+			return models.VoteEvent{}, fmt.Errorf("invalid options: %w", err)
+		}
+	}
+
+	return models.VoteEvent{
+		ID:        msg.ID,
+		PollID:    field("poll_id"),
+		UserID:    field("user_id"),
+		Option:    field("option"),
+		Options:   options,
+		Timestamp: timestamp,
+		ClientIP:  field("client_ip"),
+		UserAgent: field("user_agent"),
+	}, nil
+}
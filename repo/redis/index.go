@@ -0,0 +1,586 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"poll/models"
+	"poll/repo"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	scanBatchSize   = 1000
+
+	// indexVersion is bumped whenever a new index is introduced, so the
+	// backfill runs again for polls stored before it existed.
+	indexVersion = 4
+
+	// maxSelectionSize is the most polls a filtered listing fetches and
+	// orders at once; larger selections walk the lex index instead.
+	maxSelectionSize = 1000
+
+	// lexBatchSize is how many lex index entries a filtered walk reads at once.
+	lexBatchSize = 100
+
+	maxWatchRetries = 5
+)
+
+func (s *RedisRepo) generateIndexKey() string {
+	return fmt.Sprintf("%s:index:created", appID)
+}
+
+func (s *RedisRepo) generateLexIndexKey() string {
+	return fmt.Sprintf("%s:index:created:lex", appID)
+}
+
+func (s *RedisRepo) generateVotesIndexKey() string {
+	return fmt.Sprintf("%s:index:votes", appID)
+}
+
+func (s *RedisRepo) generateVotesLexIndexKey() string {
+	return fmt.Sprintf("%s:index:votes:lex", appID)
+}
+
+func (s *RedisRepo) generateStatusIndexKey(status models.PollStatus) string {
+	return fmt.Sprintf("%s:index:status:%s", appID, status)
+}
+
+func (s *RedisRepo) generateCreatorIndexKey(creator string) string {
+	return fmt.Sprintf("%s:index:creator:%s", appID, creator)
+}
+
+func (s *RedisRepo) generateTagIndexKey(tag string) string {
+	return fmt.Sprintf("%s:index:tag:%s", appID, tag)
+}
+
+func (s *RedisRepo) generateTermIndexKey(term string) string {
+	return fmt.Sprintf("%s:index:term:%s", appID, term)
+}
+
+func (s *RedisRepo) generateIndexBuiltKey() string {
+	return fmt.Sprintf("%s:index:built", appID)
+}
+
+// indexVotesFunc moves a poll to its total votes, the sum of the counts in
+// its votes hash, in the votes index and its lex index, or removes it from
+// both when no votes hash is given. The lex member is derived from the score,
+// so the old one is found through the votes index.
+const indexVotesFunc = `
+local function indexVotes(index, lex, poll, votes)
+	local previous = redis.call('ZSCORE', index, poll)
+	if previous then
+		redis.call('ZREM', lex, string.format('%020d', tonumber(previous)) .. ':' .. poll)
+	end
+	if not votes then
+		redis.call('ZREM', index, poll)
+		return
+	end
+	local total = 0
+	for _, count in ipairs(redis.call('HVALS', votes)) do
+		total = total + tonumber(count)
+	end
+	redis.call('ZADD', index, total, poll)
+	redis.call('ZADD', lex, 0, string.format('%020d', total) .. ':' .. poll)
+end
+`
+
+// indexVotesScript runs indexVotesFunc on the votes indexes (KEYS[1..2]) for
+// poll ARGV[1] and its votes hash (KEYS[3], if any). It replies OK rather
+// than nil, which a pipeline would report as an error.
+var indexVotesScript = redis.NewScript(indexVotesFunc + `
+indexVotes(KEYS[1], KEYS[2], ARGV[1], KEYS[3])
+return redis.status_reply('OK')
+`)
+
+// index adds the poll to the listing indexes. The created index orders polls
+// by creation time, the votes index by total votes; the latter is kept up to
+// date by indexVotes wherever counts change. Each has a lex index
+// alongside, see listing.
+func (s *RedisRepo) index(ctx context.Context, pipe redis.Pipeliner, poll models.Poll) {
+	created := float64(poll.CreatedAt.UnixMilli())
+	pipe.ZAdd(ctx, s.generateIndexKey(), &redis.Z{
+		Score:  created,
+		Member: poll.ID.String(),
+	})
+	pipe.ZAdd(ctx, s.generateLexIndexKey(), &redis.Z{
+		Member: lexMember(createdSortKey(created), poll.ID.String()),
+	})
+	s.indexAttributes(ctx, pipe, poll)
+}
+
+func (s *RedisRepo) unindex(ctx context.Context, pipe redis.Pipeliner, poll models.Poll) {
+	pipe.ZRem(ctx, s.generateIndexKey(), poll.ID.String())
+	pipe.ZRem(ctx, s.generateLexIndexKey(), lexMember(createdSortKey(float64(poll.CreatedAt.UnixMilli())), poll.ID.String()))
+	s.unindexVotes(ctx, pipe, poll.ID.String())
+	s.unindexAttributes(ctx, pipe, poll)
+}
+
+// indexVotes queues moving the poll to its total votes in the votes indexes,
+// as counted once the commands queued before it have run.
+func (s *RedisRepo) indexVotes(ctx context.Context, pipe redis.Pipeliner, pollID string) {
+	keys := []string{s.generateVotesIndexKey(), s.generateVotesLexIndexKey(), s.generateVotesKey(pollID)}
+	indexVotesScript.Eval(ctx, pipe, keys, pollID)
+}
+
+func (s *RedisRepo) unindexVotes(ctx context.Context, pipe redis.Pipeliner, pollID string) {
+	keys := []string{s.generateVotesIndexKey(), s.generateVotesLexIndexKey()}
+	indexVotesScript.Eval(ctx, pipe, keys, pollID)
+}
+
+// indexAttributes adds the poll to the sets the listing filters on.
+func (s *RedisRepo) indexAttributes(ctx context.Context, pipe redis.Pipeliner, poll models.Poll) {
+	for _, key := range s.attributeKeys(poll) {
+		pipe.SAdd(ctx, key, poll.ID.String())
+	}
+}
+
+func (s *RedisRepo) unindexAttributes(ctx context.Context, pipe redis.Pipeliner, poll models.Poll) {
+	for _, key := range s.attributeKeys(poll) {
+		pipe.SRem(ctx, key, poll.ID.String())
+	}
+}
+
+func (s *RedisRepo) attributeKeys(poll models.Poll) []string {
+	keys := []string{s.generateStatusIndexKey(poll.Status)}
+	if poll.CreatedBy != "" {
+		keys = append(keys, s.generateCreatorIndexKey(poll.CreatedBy))
+	}
+	for _, tag := range poll.Tags {
+		keys = append(keys, s.generateTagIndexKey(tag))
+	}
+	for _, term := range repo.SearchTerms(poll) {
+		keys = append(keys, s.generateTermIndexKey(term))
+	}
+	return keys
+}
+
+// listing is an order polls are listed in. Its lex index holds every poll as
+// "<sort key>:<poll ID>" at score 0, so ZRANGEBYLEX orders ties by poll ID and
+// a page seeks straight past the cursor, however many polls share its score.
+// The score index holds the same order as plain scores.
+type listing struct {
+	lexKey   string
+	scoreKey string
+	sortKey  func(score float64) string
+	score    func(sortKey uint64) float64
+}
+
+func (s *RedisRepo) listing(sort models.PollSort) listing {
+	if sort == models.SortVotes {
+		return listing{
+			lexKey:   s.generateVotesLexIndexKey(),
+			scoreKey: s.generateVotesIndexKey(),
+			sortKey:  votesSortKey,
+			score:    func(sortKey uint64) float64 { return float64(sortKey) },
+		}
+	}
+	return listing{
+		lexKey:   s.generateLexIndexKey(),
+		scoreKey: s.generateIndexKey(),
+		sortKey:  createdSortKey,
+		score:    func(sortKey uint64) float64 { return float64(int64(sortKey ^ 1<<63)) },
+	}
+}
+
+// createdSortKey encodes a creation time in Unix milliseconds as a fixed width
+// string, with the sign bit flipped so times before 1970 sort first.
+func createdSortKey(score float64) string {
+	return fmt.Sprintf("%020d", uint64(int64(score))^1<<63)
+}
+
+// votesSortKey encodes a total of votes as a fixed width string, the way
+// indexVotesFunc does.
+func votesSortKey(score float64) string {
+	return fmt.Sprintf("%020d", uint64(score))
+}
+
+func lexMember(sortKey, pollID string) string {
+	return sortKey + ":" + pollID
+}
+
+// entry decodes a lex index member into the cursor pointing at it.
+func (l listing) entry(member string) (repo.Cursor, error) {
+	sortKey, pollID, ok := strings.Cut(member, ":")
+	if !ok {
+		return repo.Cursor{}, fmt.Errorf("invalid index entry %q", member)
+	}
+	key, err := strconv.ParseUint(sortKey, 10, 64)
+	if err != nil {
+		return repo.Cursor{}, fmt.Errorf("invalid index entry %q: %w", member, err)
+	}
+	return repo.Cursor{Score: l.score(key), Member: pollID}, nil
+}
+
+// lexRange bounds a lex index range, in ZRANGEBYLEX syntax.
+type lexRange struct {
+	Min string
+	Max string
+}
+
+var fullRange = lexRange{Min: "-", Max: "+"}
+
+// contains reports whether the member falls within the range.
+func (r lexRange) contains(member string) bool {
+	switch r.Min[0] {
+	case '[':
+		if member < r.Min[1:] {
+			return false
+		}
+	case '(':
+		if member <= r.Min[1:] {
+			return false
+		}
+	}
+	switch r.Max[0] {
+	case '[':
+		return member <= r.Max[1:]
+	case '(':
+		return member < r.Max[1:]
+	}
+	return true
+}
+
+// createdRange converts the query's creation time bounds, inclusive of
+// CreatedAfter and exclusive of CreatedBefore, to a range of the created lex
+// index. Every member extends its sort key, so the bare key of CreatedAfter
+// sorts before all polls created at that time and the one of CreatedBefore
+// before all polls created at that time.
+func createdRange(query models.PollQuery) lexRange {
+	bounds := fullRange
+	if query.CreatedAfter != nil {
+		bounds.Min = "[" + createdSortKey(float64(query.CreatedAfter.UnixMilli()))
+	}
+	if query.CreatedBefore != nil {
+		bounds.Max = "(" + createdSortKey(float64(query.CreatedBefore.UnixMilli()))
+	}
+	return bounds
+}
+
+// createdWithin reports whether a creation time in Unix milliseconds falls
+// within the query's creation time bounds.
+func createdWithin(query models.PollQuery, created float64) bool {
+	return (query.CreatedAfter == nil || created >= float64(query.CreatedAfter.UnixMilli())) &&
+		(query.CreatedBefore == nil || created < float64(query.CreatedBefore.UnixMilli()))
+}
+
+// selectPolls returns up to limit polls matching the query, starting after the
+// cursor, and whether more follow. When the filters or the creation time
+// bounds narrow the polls down to at most maxSelectionSize candidates, those
+// are fetched and ordered directly. Otherwise the lex index is walked from the
+// cursor and each batch is checked against the filters, so no listing ever
+// copies a whole index.
+func (s *RedisRepo) selectPolls(ctx context.Context, query models.PollQuery, after *repo.Cursor, limit int) ([]repo.Cursor, bool, error) {
+	var filters []string
+	if query.Status != "" {
+		filters = append(filters, s.generateStatusIndexKey(query.Status))
+	}
+	if query.CreatedBy != "" {
+		filters = append(filters, s.generateCreatorIndexKey(query.CreatedBy))
+	}
+	for _, tag := range query.Tags {
+		filters = append(filters, s.generateTagIndexKey(tag))
+	}
+	for _, term := range repo.QueryTerms(query.Search) {
+		filters = append(filters, s.generateTermIndexKey(term))
+	}
+
+	l := s.listing(query.Sort)
+	bounds := fullRange
+	// The creation time bounds only map onto the created index; listings
+	// sorted by votes check each poll's creation time instead.
+	checkCreated := query.Sort == models.SortVotes && (query.CreatedAfter != nil || query.CreatedBefore != nil)
+	if !checkCreated {
+		bounds = createdRange(query)
+	}
+	if after != nil {
+		seek := "(" + lexMember(l.sortKey(after.Score), after.Member)
+		if query.Ascending {
+			bounds.Min = seek
+		} else {
+			bounds.Max = seek
+		}
+	}
+
+	if len(filters) == 0 && !checkCreated {
+		return s.pageByLex(ctx, l, bounds, limit, query.Ascending, nil)
+	}
+
+	candidates, ok, err := s.candidates(ctx, query, filters, checkCreated)
+	if err != nil {
+		return nil, false, err
+	} else if ok {
+		return s.pageCandidates(ctx, l, bounds, limit, query, candidates, checkCreated)
+	}
+
+	match := func(ctx context.Context, pollIDs []string) ([]bool, error) {
+		return s.matchPolls(ctx, query, filters, checkCreated, pollIDs)
+	}
+	return s.pageByLex(ctx, l, bounds, limit, query.Ascending, match)
+}
+
+// candidates returns a superset of the polls matching the query when it holds
+// at most maxSelectionSize polls: the intersection of the filters if the
+// smallest is that small, or else the polls created within the query's bounds
+// if few enough. It reports false when there is no such superset.
+func (s *RedisRepo) candidates(ctx context.Context, query models.PollQuery, filters []string, checkCreated bool) ([]string, bool, error) {
+	if len(filters) > 0 {
+		pipe := s.client.Pipeline()
+		sizes := make([]*redis.IntCmd, len(filters))
+		for i, filter := range filters {
+			sizes[i] = pipe.SCard(ctx, filter)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, false, err
+		}
+
+		for _, size := range sizes {
+			if size.Val() <= maxSelectionSize {
+				pollIDs, err := s.client.SInter(ctx, filters...).Result()
+				return pollIDs, err == nil, err
+			}
+		}
+	}
+
+	if !checkCreated {
+		return nil, false, nil
+	}
+
+	bounds := createdRange(query)
+	size, err := s.client.ZLexCount(ctx, s.generateLexIndexKey(), bounds.Min, bounds.Max).Result()
+	if err != nil || size > maxSelectionSize {
+		return nil, false, err
+	}
+	members, err := s.client.ZRangeByLex(ctx, s.generateLexIndexKey(), &redis.ZRangeBy{Min: bounds.Min, Max: bounds.Max}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	pollIDs := make([]string, len(members))
+	for i, member := range members {
+		_, pollIDs[i], _ = strings.Cut(member, ":")
+	}
+	return pollIDs, true, nil
+}
+
+// pageCandidates returns up to limit of the candidate polls that match the
+// query and fall within bounds, in listing order, and whether more follow.
+func (s *RedisRepo) pageCandidates(ctx context.Context, l listing, bounds lexRange, limit int, query models.PollQuery, pollIDs []string, checkCreated bool) ([]repo.Cursor, bool, error) {
+	if len(pollIDs) == 0 {
+		return nil, false, nil
+	}
+
+	pipe := s.client.Pipeline()
+	scores := pipe.ZMScore(ctx, l.scoreKey, pollIDs...)
+	var created *redis.FloatSliceCmd
+	if checkCreated {
+		created = pipe.ZMScore(ctx, s.generateIndexKey(), pollIDs...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, err
+	}
+
+	members := make([]string, 0, len(pollIDs))
+	for i, pollID := range pollIDs {
+		if created != nil && !createdWithin(query, created.Val()[i]) {
+			continue
+		}
+		member := lexMember(l.sortKey(scores.Val()[i]), pollID)
+		if bounds.contains(member) {
+			members = append(members, member)
+		}
+	}
+
+	sort.Strings(members)
+	if !query.Ascending {
+		slices.Reverse(members)
+	}
+
+	more := len(members) > limit
+	if more {
+		members = members[:limit]
+	}
+
+	page := make([]repo.Cursor, len(members))
+	for i, member := range members {
+		entry, err := l.entry(member)
+		if err != nil {
+			return nil, false, err
+		}
+		page[i] = entry
+	}
+	return page, more, nil
+}
+
+// matchPolls reports which of the polls are in all filters and, if
+// checkCreated is set, were created within the query's bounds.
+func (s *RedisRepo) matchPolls(ctx context.Context, query models.PollQuery, filters []string, checkCreated bool, pollIDs []string) ([]bool, error) {
+	members := make([]interface{}, len(pollIDs))
+	for i, pollID := range pollIDs {
+		members[i] = pollID
+	}
+
+	pipe := s.client.Pipeline()
+	in := make([]*redis.BoolSliceCmd, len(filters))
+	for i, filter := range filters {
+		in[i] = pipe.SMIsMember(ctx, filter, members...)
+	}
+	var created *redis.FloatSliceCmd
+	if checkCreated {
+		created = pipe.ZMScore(ctx, s.generateIndexKey(), pollIDs...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	matches := make([]bool, len(pollIDs))
+	for i := range pollIDs {
+		matches[i] = created == nil || createdWithin(query, created.Val()[i])
+		for _, cmd := range in {
+			matches[i] = matches[i] && cmd.Val()[i]
+		}
+	}
+	return matches, nil
+}
+
+// pageByLex walks the listing's lex index within bounds and returns up to
+// limit entries accepted by match, or all entries when match is nil, and
+// whether more follow. Each batch resumes right after the last entry read.
+func (s *RedisRepo) pageByLex(ctx context.Context, l listing, bounds lexRange, limit int, ascending bool, match func(ctx context.Context, pollIDs []string) ([]bool, error)) ([]repo.Cursor, bool, error) {
+	batch := int64(limit + 1)
+	if match != nil && batch < lexBatchSize {
+		batch = lexBatchSize
+	}
+
+	page := make([]repo.Cursor, 0, limit+1)
+	for {
+		by := &redis.ZRangeBy{Min: bounds.Min, Max: bounds.Max, Count: batch}
+
+		var members []string
+		var err error
+		if ascending {
+			members, err = s.client.ZRangeByLex(ctx, l.lexKey, by).Result()
+		} else {
+			members, err = s.client.ZRevRangeByLex(ctx, l.lexKey, by).Result()
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		entries := make([]repo.Cursor, len(members))
+		pollIDs := make([]string, len(members))
+		for i, member := range members {
+			if entries[i], err = l.entry(member); err != nil {
+				return nil, false, err
+			}
+			pollIDs[i] = entries[i].Member
+		}
+
+		var matches []bool
+		if match != nil && len(pollIDs) > 0 {
+			if matches, err = match(ctx, pollIDs); err != nil {
+				return nil, false, err
+			}
+		}
+
+		for i, entry := range entries {
+			if matches != nil && !matches[i] {
+				continue
+			}
+
+			page = append(page, entry)
+			if len(page) > limit {
+				return page[:limit], true, nil
+			}
+		}
+
+		if int64(len(members)) < batch {
+			return page, false, nil
+		}
+
+		seek := "(" + members[len(members)-1]
+		if ascending {
+			bounds.Min = seek
+		} else {
+			bounds.Max = seek
+		}
+	}
+}
+
+// watch runs fn in a WATCH transaction on keys, retrying when the keys change
+// before the transaction commits.
+func (s *RedisRepo) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	var err error
+	for i := 0; i < maxWatchRetries; i++ {
+		err = s.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// backfillIndex adds polls stored before the indexes existed to them. It walks
+// the keyspace with SCAN, so Redis is never blocked, and only runs until it
+// has completed once for the current indexVersion.
+func (s *RedisRepo) backfillIndex(ctx context.Context) error {
+	built, err := s.client.Get(ctx, s.generateIndexBuiltKey()).Int()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if built >= indexVersion {
+		return nil
+	}
+
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, appID+":*", scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := s.indexKeys(ctx, keys); err != nil {
+			return err
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return s.client.Set(ctx, s.generateIndexBuiltKey(), indexVersion, 0).Err()
+}
+
+func (s *RedisRepo) indexKeys(ctx context.Context, keys []string) error {
+	var pollIDs []string
+	for _, key := range keys {
+		pollID := strings.TrimPrefix(key, appID+":")
+		if _, err := uuid.Parse(pollID); err == nil {
+			pollIDs = append(pollIDs, pollID)
+		}
+	}
+	if len(pollIDs) == 0 {
+		return nil
+	}
+
+	polls, err := s.getPolls(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, poll := range polls {
+			s.index(ctx, pipe, poll)
+			s.indexVotes(ctx, pipe, poll.ID.String())
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"poll/models"
+)
+
+// legacyPoll is the stored shape of polls created before options had IDs.
+// Their options were plain labels, and votes and ballots referred to them by
+// label.
+type legacyPoll struct {
+	models.Poll
+	Options []string `json:"options"`
+}
+
+func isLegacyPoll(data []byte) bool {
+	var probe struct {
+		Options []json.RawMessage `json:"options"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil || len(probe.Options) == 0 {
+		return false
+	}
+	return len(probe.Options[0]) > 0 && probe.Options[0][0] == '"'
+}
+
+// unmarshalLegacyPoll converts a legacy poll to the current shape, giving
+// every option the ID derived by models.LegacyOptionID.
+func unmarshalLegacyPoll(data []byte) (models.Poll, error) {
+	var legacy legacyPoll
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return models.Poll{}, err
+	}
+
+	poll := legacy.Poll
+	poll.Options = make([]models.Option, len(legacy.Options))
+	for i, label := range legacy.Options {
+		poll.Options[i] = models.Option{
+			ID:    models.LegacyOptionID(poll.ID, label),
+			Label: label,
+			Order: i,
+		}
+	}
+
+	if legacy.Votes != nil {
+		poll.Votes = make(map[string]int, len(legacy.Votes))
+		for label, count := range legacy.Votes {
+			poll.Votes[models.LegacyOptionID(poll.ID, label)] = count
+		}
+	}
+
+	return poll, nil
+}
+
+// migratePoll rewrites a legacy poll, its vote counters and its ballots to
+// refer to options by ID. Polls from before vote counters moved out of the
+// poll blob get their counters seeded from the blob. It is a no-op if the
+// poll is gone or was migrated concurrently.
+func (s *RedisRepo) migratePoll(ctx context.Context, pollID string) error {
+	key, votesKey, votersKey := s.generateKey(pollID), s.generateVotesKey(pollID), s.generateVotersKey(pollID)
+
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !isLegacyPoll(data) {
+			return nil
+		}
+
+		poll, err := unmarshalLegacyPoll(data)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal legacy poll data: %w", err)
+		}
+
+		counters, err := tx.HGetAll(ctx, votesKey).Result()
+		if err != nil {
+			return err
+		}
+		labelVotes, err := parseVotes(counters)
+		if err != nil {
+			return err
+		}
+
+		votes := poll.Votes
+		if len(labelVotes) > 0 {
+			votes = make(map[string]int, len(labelVotes))
+			for label, count := range labelVotes {
+				votes[models.LegacyOptionID(poll.ID, label)] = count
+			}
+		}
+
+		voters, err := tx.HGetAll(ctx, votersKey).Result()
+		if err != nil {
+			return err
+		}
+		ballots := make(map[string]string, len(voters))
+		for userID, value := range voters {
+			labels, err := parseBallot(value)
+			if err != nil {
+				return fmt.Errorf("invalid ballot of user %s: %w", userID, err)
+			}
+			ballot, err := json.Marshal(legacyOptionIDs(poll.ID, labels))
+			if err != nil {
+				return err
+			}
+			ballots[userID] = string(ballot)
+		}
+
+		migrated, err := marshalPoll(poll)
+		if err != nil {
+			return fmt.Errorf("failed to marshal poll data: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, migrated, 0)
+			pipe.Del(ctx, votesKey, votersKey)
+			for optionID, count := range votes {
+				pipe.HSet(ctx, votesKey, optionID, count)
+			}
+			for userID, ballot := range ballots {
+				pipe.HSet(ctx, votersKey, userID, ballot)
+			}
+			return nil
+		})
+		return err
+	}, key, votesKey, votersKey)
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil
+	}
+
+	return err
+}
+
+func legacyOptionIDs(pollID uuid.UUID, labels []string) []string {
+	ids := make([]string, len(labels))
+	for i, label := range labels {
+		ids[i] = models.LegacyOptionID(pollID, label)
+	}
+	return ids
+}
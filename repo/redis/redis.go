@@ -3,10 +3,14 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"poll/configs"
 	"poll/models"
+	"poll/repo"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +20,60 @@ const (
 
 var Nil = redis.Nil
 
+const errAlreadyVoted = "ALREADY_VOTED"
+
+// maxMigrationAttempts bounds how often GetPoll reads a legacy poll and
+// migrates it, in case other writes keep aborting the migration.
+const maxMigrationAttempts = 3
+
+// recordVoteScript remembers the voter's ballot (a JSON array of choices) in
+// the voters hash, bumps the counters of the options the ballot counts for in
+// the votes hash and returns the full votes hash, so callers get fresh totals
+// in one round trip. A repeated vote either moves the voter's counts to the
+// new ballot or fails, depending on ARGV[3]. ARGV[4] marks ranked ballots,
+// which only count their first preference. Voters recorded before ballots
+// were stored as arrays hold a bare option instead. The poll (ARGV[5]) then
+// moves to its new total in the votes index (KEYS[4]) and its lex index
+// (KEYS[6]). Every accepted vote is appended to the poll's vote event stream
+// (KEYS[5]) as the fields and values in ARGV[6..], so counts and events never
+// disagree.
+// It returns nil when the poll itself no longer exists.
+var recordVoteScript = redis.NewScript(indexVotesFunc + `
+redis.replicate_commands()
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+local function counted(ballot)
+	if string.sub(ballot, 1, 1) ~= '[' then
+		return {ballot}
+	end
+	local choices = cjson.decode(ballot)
+	if ARGV[4] == '1' then
+		return {choices[1]}
+	end
+	return choices
+end
+local previous = redis.call('HGET', KEYS[3], ARGV[1])
+if previous and ARGV[3] ~= '1' then
+	return redis.error_reply('` + errAlreadyVoted + `')
+end
+redis.call('XADD', KEYS[5], '*', unpack(ARGV, 6))
+if previous then
+	if previous == ARGV[2] then
+		return redis.call('HGETALL', KEYS[2])
+	end
+	for _, option in ipairs(counted(previous)) do
+		redis.call('HINCRBY', KEYS[2], option, -1)
+	end
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+for _, option in ipairs(counted(ARGV[2])) do
+	redis.call('HINCRBY', KEYS[2], option, 1)
+end
+indexVotes(KEYS[4], KEYS[6], ARGV[5], KEYS[2])
+return redis.call('HGETALL', KEYS[2])
+`)
+
 type Config struct {
 	Timeout time.Duration
 }
@@ -38,10 +96,16 @@ func New(ctx context.Context, cfg configs.RepoConfig) (*RedisRepo, error) {
 		return nil, fmt.Errorf("basic connection failure: %v", err)
 	}
 
-	return &RedisRepo{
+	r := &RedisRepo{
 		client: client,
 		cfg:    cfg,
-	}, nil
+	}
+
+	if err := r.backfillIndex(ctx); err != nil {
+		return nil, fmt.Errorf("failed to build poll index: %v", err)
+	}
+
+	return r, nil
 }
 
 func (r *RedisRepo) Close() error {
@@ -52,17 +116,93 @@ func (s *RedisRepo) generateKey(pollID string) string {
 	return fmt.Sprintf("%s:%s", appID, pollID)
 }
 
+func (s *RedisRepo) generateScheduleKey() string {
+	return fmt.Sprintf("%s:schedule", appID)
+}
+
+// schedule keeps the poll's entry in the schedule index in line with its
+// next transition.
+func (s *RedisRepo) schedule(ctx context.Context, pipe redis.Pipeliner, pollID string, poll models.Poll) {
+	at, ok := poll.NextTransition()
+	if !ok {
+		pipe.ZRem(ctx, s.generateScheduleKey(), pollID)
+		return
+	}
+
+	pipe.ZAdd(ctx, s.generateScheduleKey(), &redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: pollID,
+	})
+}
+
+func (s *RedisRepo) generateVotesKey(pollID string) string {
+	return fmt.Sprintf("%s:%s:votes", appID, pollID)
+}
+
+func (s *RedisRepo) generateVotersKey(pollID string) string {
+	return fmt.Sprintf("%s:%s:voters", appID, pollID)
+}
+
+// marshalPoll encodes the poll definition. Vote counts live in a separate
+// hash so they can be incremented atomically, and are never stored in the blob.
+func marshalPoll(poll models.Poll) ([]byte, error) {
+	poll.Votes = nil
+	return json.Marshal(poll)
+}
+
+// unmarshalPoll decodes a stored poll definition. Polls stored before
+// lifecycle states existed always accepted votes, so they are read as open;
+// those stored before ballot types existed are single-choice, those stored
+// before versions existed are at version 1, those stored before results
+// visibility existed show their results always, and those stored before
+// options had IDs are converted, see unmarshalLegacyPoll.
+func unmarshalPoll(data []byte) (*models.Poll, error) {
+	var poll models.Poll
+	if isLegacyPoll(data) {
+		legacy, err := unmarshalLegacyPoll(data)
+		if err != nil {
+			return nil, err
+		}
+		poll = legacy
+	} else if err := json.Unmarshal(data, &poll); err != nil {
+		return nil, err
+	}
+
+	if poll.Status == "" {
+		poll.Status = models.StatusOpen
+	}
+	if poll.BallotType == "" {
+		poll.BallotType = models.BallotSingle
+	}
+	if poll.Version == 0 {
+		poll.Version = 1
+	}
+	if poll.ResultsVisibility == "" {
+		poll.ResultsVisibility = models.ResultsAlways
+	}
+
+	return &poll, nil
+}
+
+func parseVotes(fields map[string]string) (map[string]int, error) {
+	votes := make(map[string]int, len(fields))
+	for option, value := range fields {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vote count for option %s: %w", option, err)
+		}
+		votes[option] = count
+	}
+	return votes, nil
+}
+
 func (s *RedisRepo) CreatePoll(ctx context.Context, pollID string, poll models.Poll) error {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
 	defer cancel()
 
-	data, err := json.Marshal(poll)
-	if err != nil {
-		return fmt.Errorf("failed to marshal poll data: %w", err)
-	}
-
-	key := s.generateKey(pollID)
-	err = s.client.Set(ctxWithTimeout, key, data, 0).Err()
+	_, err := s.client.TxPipelined(ctxWithTimeout, func(pipe redis.Pipeliner) error {
+		return s.createPoll(ctxWithTimeout, pipe, pollID, poll)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to save poll %s: %w", pollID, err)
 	}
@@ -70,62 +210,217 @@ func (s *RedisRepo) CreatePoll(ctx context.Context, pollID string, poll models.P
 	return nil
 }
 
-func (s *RedisRepo) GetPoll(ctx context.Context, pollID string) (*models.Poll, error) {
+// CreatePolls writes all polls in a single MULTI/EXEC transaction.
+func (s *RedisRepo) CreatePolls(ctx context.Context, polls []models.Poll) error {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
 	defer cancel()
 
-	key := s.generateKey(pollID)
-	data, err := s.client.Get(ctxWithTimeout, key).Result()
-	if err == redis.Nil {
-		return nil, fmt.Errorf("poll %s not found", pollID)
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to get poll %s: %w", pollID, err)
+	_, err := s.client.TxPipelined(ctxWithTimeout, func(pipe redis.Pipeliner) error {
+		for _, poll := range polls {
+			if err := s.createPoll(ctxWithTimeout, pipe, poll.ID.String(), poll); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %d polls: %w", len(polls), err)
 	}
 
-	var poll models.Poll
-	err = json.Unmarshal([]byte(data), &poll)
+	return nil
+}
+
+// createPoll queues the commands storing the poll at version 1, with its
+// votes, schedule and index entries.
+func (s *RedisRepo) createPoll(ctx context.Context, pipe redis.Pipeliner, pollID string, poll models.Poll) error {
+	poll.Version = 1
+	data, err := marshalPoll(poll)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal poll data: %w", err)
+		return fmt.Errorf("failed to marshal poll data: %w", err)
 	}
 
-	return &poll, nil
+	pipe.Set(ctx, s.generateKey(pollID), data, 0)
+	for option, count := range poll.Votes {
+		pipe.HSet(ctx, s.generateVotesKey(pollID), option, count)
+	}
+	s.schedule(ctx, pipe, pollID, poll)
+	s.index(ctx, pipe, poll)
+	s.indexVotes(ctx, pipe, pollID)
+	return nil
 }
 
-func (s *RedisRepo) ListPolls(ctx context.Context) ([]models.Poll, error) {
+// GetPoll returns the poll with its vote counts, migrating it first if it
+// is a legacy poll.
+func (s *RedisRepo) GetPoll(ctx context.Context, pollID string) (*models.Poll, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
 	defer cancel()
 
-	keys, err := s.client.Keys(ctxWithTimeout, "appID:*").Result()
+	for attempt := 1; ; attempt++ {
+		pipe := s.client.Pipeline()
+		getCmd := pipe.Get(ctxWithTimeout, s.generateKey(pollID))
+		votesCmd := pipe.HGetAll(ctxWithTimeout, s.generateVotesKey(pollID))
+		_, err := pipe.Exec(ctxWithTimeout)
+
+		data, getErr := getCmd.Result()
+		if getErr == redis.Nil {
+			return nil, fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get poll %s: %w", pollID, err)
+		}
+
+		if isLegacyPoll([]byte(data)) {
+			if attempt == maxMigrationAttempts {
+				return nil, fmt.Errorf("failed to migrate poll %s: still legacy after %d attempts", pollID, attempt)
+			}
+			if err := s.migratePoll(ctxWithTimeout, pollID); err != nil {
+				return nil, fmt.Errorf("failed to migrate poll %s: %w", pollID, err)
+			}
+			continue
+		}
+
+		poll, err := unmarshalPoll([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal poll data: %w", err)
+		}
+
+		votes, err := parseVotes(votesCmd.Val())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read votes for poll %s: %w", pollID, err)
+		}
+
+		poll.Votes = votes
+
+		return poll, nil
+	}
+}
+
+func (s *RedisRepo) ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	after, err := repo.DecodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	entries, more, err := s.selectPolls(ctxWithTimeout, query, after, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list polls: %w", err)
 	}
 
-	var polls []models.Poll
-	for _, key := range keys {
-		data, err := s.client.Get(ctxWithTimeout, key).Result()
+	pollIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		pollIDs[i] = entry.Member
+	}
+
+	polls, err := s.getPolls(ctxWithTimeout, pollIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %w", err)
+	}
+
+	page := &models.PollPage{Polls: polls}
+	if more {
+		page.NextCursor = entries[len(entries)-1].Encode()
+	}
+
+	return page, nil
+}
+
+// getPolls loads the polls with their votes in a single round trip. Polls
+// deleted in the meantime are skipped.
+func (s *RedisRepo) getPolls(ctx context.Context, pollIDs []string) ([]models.Poll, error) {
+	polls := make([]models.Poll, 0, len(pollIDs))
+	if len(pollIDs) == 0 {
+		return polls, nil
+	}
+
+	keys := make([]string, len(pollIDs))
+	for i, pollID := range pollIDs {
+		keys[i] = s.generateKey(pollID)
+	}
+
+	pipe := s.client.Pipeline()
+	getCmd := pipe.MGet(ctx, keys...)
+	votesCmds := make([]*redis.StringStringMapCmd, len(pollIDs))
+	for i, pollID := range pollIDs {
+		votesCmds[i] = pipe.HGetAll(ctx, s.generateVotesKey(pollID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, value := range getCmd.Val() {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		if isLegacyPoll([]byte(data)) {
+			poll, err := s.GetPoll(ctx, pollIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			polls = append(polls, *poll)
+			continue
+		}
+
+		poll, err := unmarshalPoll([]byte(data))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get poll for key %s: %w", key, err)
+			return nil, fmt.Errorf("failed to unmarshal poll data for poll %s: %w", pollIDs[i], err)
 		}
 
-		var poll models.Poll
-		err = json.Unmarshal([]byte(data), &poll)
+		poll.Votes, err = parseVotes(votesCmds[i].Val())
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal poll data for key %s: %w", key, err)
+			return nil, fmt.Errorf("failed to read votes for poll %s: %w", pollIDs[i], err)
 		}
 
-		polls = append(polls, poll)
+		polls = append(polls, *poll)
 	}
 
 	return polls, nil
 }
 
-func (s *RedisRepo) DeletePoll(ctx context.Context, pollID string) error {
+func (s *RedisRepo) DeletePoll(ctx context.Context, pollID string, version int64) error {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
 	defer cancel()
 
 	key := s.generateKey(pollID)
-	err := s.client.Del(ctxWithTimeout, key).Err()
-	if err != nil {
+	err := s.watch(ctxWithTimeout, func(tx *redis.Tx) error {
+		previous, err := s.storedPoll(ctxWithTimeout, tx, key)
+		if err != nil {
+			return err
+		}
+		if version != 0 {
+			if previous == nil {
+				return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+			} else if previous.Version != version {
+				return fmt.Errorf("%w: poll %s is at version %d", repo.ErrVersionConflict, pollID, previous.Version)
+			}
+		}
+
+		_, err = tx.TxPipelined(ctxWithTimeout, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctxWithTimeout,
+				key,
+				s.generateVotesKey(pollID),
+				s.generateVotersKey(pollID),
+				s.generateEventsKey(pollID),
+			)
+			pipe.ZRem(ctxWithTimeout, s.generateScheduleKey(), pollID)
+			if previous != nil {
+				s.unindex(ctxWithTimeout, pipe, *previous)
+			}
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrVersionConflict) {
+		return err
+	} else if err != nil {
 		return fmt.Errorf("failed to delete poll %s: %w", pollID, err)
 	}
 
@@ -136,16 +431,209 @@ func (s *RedisRepo) UpdatePoll(ctx context.Context, pollID string, poll models.P
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
 	defer cancel()
 
-	data, err := json.Marshal(poll)
+	expected := poll.Version
+	poll.Version++
+	data, err := marshalPoll(poll)
 	if err != nil {
 		return fmt.Errorf("failed to marshal poll data: %w", err)
 	}
 
 	key := s.generateKey(pollID)
-	err = s.client.Set(ctxWithTimeout, key, data, 0).Err()
-	if err != nil {
+	err = s.watch(ctxWithTimeout, func(tx *redis.Tx) error {
+		previous, err := s.storedPoll(ctxWithTimeout, tx, key)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+		} else if previous.Version != expected {
+			return fmt.Errorf("%w: poll %s is at version %d", repo.ErrVersionConflict, pollID, previous.Version)
+		}
+
+		_, err = tx.TxPipelined(ctxWithTimeout, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctxWithTimeout, key, data, 0)
+			// Counts of removed options go with them.
+			if removed := removedOptions(*previous, poll); len(removed) > 0 {
+				pipe.HDel(ctxWithTimeout, s.generateVotesKey(pollID), removed...)
+				s.indexVotes(ctxWithTimeout, pipe, pollID)
+			}
+			s.schedule(ctxWithTimeout, pipe, pollID, poll)
+			s.unindexAttributes(ctxWithTimeout, pipe, *previous)
+			s.indexAttributes(ctxWithTimeout, pipe, poll)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrVersionConflict) {
+		return err
+	} else if err != nil {
 		return fmt.Errorf("failed to update poll %s: %w", pollID, err)
 	}
 
 	return nil
 }
+
+// removedOptions returns the IDs of the previous poll's options that the
+// updated poll no longer has.
+func removedOptions(previous, updated models.Poll) []string {
+	kept := make(map[string]bool, len(updated.Options))
+	for _, option := range updated.Options {
+		kept[option.ID] = true
+	}
+
+	var removed []string
+	for _, option := range previous.Options {
+		if !kept[option.ID] {
+			removed = append(removed, option.ID)
+		}
+	}
+	return removed
+}
+
+// storedPoll reads the poll as currently stored, so its index entries can be
+// removed. It returns nil if there is no such poll.
+func (s *RedisRepo) storedPoll(ctx context.Context, tx *redis.Tx, key string) (*models.Poll, error) {
+	data, err := tx.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return unmarshalPoll(data)
+}
+
+func (s *RedisRepo) RecordVote(ctx context.Context, pollID string, ballot models.Ballot, allowChange bool, event models.VoteEvent) (map[string]int, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	choices, err := json.Marshal(ballot.Choices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ballot: %w", err)
+	}
+
+	values, err := voteEventValues(event)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{s.generateKey(pollID), s.generateVotesKey(pollID), s.generateVotersKey(pollID), s.generateVotesIndexKey(), s.generateEventsKey(pollID), s.generateVotesLexIndexKey()}
+	args := append([]interface{}{ballot.UserID, choices, allowChange, ballot.Ranked, pollID}, values...)
+	res, err := recordVoteScript.Run(ctxWithTimeout, s.client, keys, args...).StringSlice()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+	} else if err != nil && strings.HasSuffix(err.Error(), errAlreadyVoted) {
+		return nil, repo.ErrAlreadyVoted
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to record vote for poll %s: %w", pollID, err)
+	}
+
+	fields := make(map[string]string, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		fields[res[i]] = res[i+1]
+	}
+
+	return parseVotes(fields)
+}
+
+func (s *RedisRepo) ListBallots(ctx context.Context, pollID string) (map[string][]string, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	fields, err := s.client.HGetAll(ctxWithTimeout, s.generateVotersKey(pollID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ballots for poll %s: %w", pollID, err)
+	}
+
+	ballots := make(map[string][]string, len(fields))
+	for userID, value := range fields {
+		choices, err := parseBallot(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ballot of user %s in poll %s: %w", userID, pollID, err)
+		}
+		ballots[userID] = choices
+	}
+
+	return ballots, nil
+}
+
+// parseBallot decodes a voters hash value, see recordVoteScript.
+func parseBallot(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") {
+		return []string{value}, nil
+	}
+
+	var choices []string
+	if err := json.Unmarshal([]byte(value), &choices); err != nil {
+		return nil, err
+	}
+	return choices, nil
+}
+
+func (s *RedisRepo) HasVoted(ctx context.Context, pollID, userID string) (bool, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	voted, err := s.client.HExists(ctxWithTimeout, s.generateVotersKey(pollID), userID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check ballot of user %s in poll %s: %w", userID, pollID, err)
+	}
+
+	return voted, nil
+}
+
+// ReplaceVotes swaps the poll's counts and ballots in one transaction, which
+// aborts if the poll is deleted in the meantime so its votes are not recreated.
+func (s *RedisRepo) ReplaceVotes(ctx context.Context, pollID string, votes map[string]int, ballots map[string][]string) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	key := s.generateKey(pollID)
+	votesKey, votersKey := s.generateVotesKey(pollID), s.generateVotersKey(pollID)
+	err := s.watch(ctxWithTimeout, func(tx *redis.Tx) error {
+		exists, err := tx.Exists(ctxWithTimeout, key).Result()
+		if err != nil {
+			return err
+		} else if exists == 0 {
+			return fmt.Errorf("%w: poll %s", repo.ErrNotFound, pollID)
+		}
+
+		_, err = tx.TxPipelined(ctxWithTimeout, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctxWithTimeout, votesKey, votersKey)
+			for option, count := range votes {
+				pipe.HSet(ctxWithTimeout, votesKey, option, count)
+			}
+			for userID, choices := range ballots {
+				data, err := json.Marshal(choices)
+				if err != nil {
+					return fmt.Errorf("failed to marshal ballot of user %s: %w", userID, err)
+				}
+				pipe.HSet(ctxWithTimeout, votersKey, userID, data)
+			}
+			s.indexVotes(ctxWithTimeout, pipe, pollID)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, repo.ErrNotFound) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to replace votes for poll %s: %w", pollID, err)
+	}
+
+	return nil
+}
+
+func (s *RedisRepo) DueScheduledPolls(ctx context.Context, now time.Time) ([]string, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration)
+	defer cancel()
+
+	pollIDs, err := s.client.ZRangeByScore(ctxWithTimeout, s.generateScheduleKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled polls: %w", err)
+	}
+
+	return pollIDs, nil
+}
@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"poll/configs"
+	"poll/models"
+	"poll/repo"
+	"poll/repo/repotest"
+	"testing"
+	"time"
+)
+
+// newTestRepo returns a repository backed by a fresh in-process Redis.
+func newTestRepo(t *testing.T) (*RedisRepo, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	r, err := New(context.Background(), configs.RepoConfig{
+		Redis:   configs.RedisConfig{Addr: mr.Addr()},
+		Timeout: configs.Duration{Duration: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	return r, mr
+}
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		r, _ := newTestRepo(t)
+		return r
+	})
+}
+
+func TestGetPollMigratesLegacyPoll(t *testing.T) {
+	r, mr := newTestRepo(t)
+	pollID := uuid.New()
+	key := "poll:" + pollID.String()
+	mr.Set(key, `{"id":"`+pollID.String()+`","question":"Q?","options":["a","b"],"votes":null}`)
+	mr.HSet(key+":votes", "b", "2")
+	mr.HSet(key+":voters", "u1", "b", "u2", `["b"]`)
+
+	poll, err := r.GetPoll(context.Background(), pollID.String())
+	if err != nil {
+		t.Fatalf("failed to get poll: %v", err)
+	}
+
+	b := models.LegacyOptionID(pollID, "b")
+	if len(poll.Options) != 2 || poll.Options[1].ID != b || poll.Votes[b] != 2 {
+		t.Fatalf("got options %+v and votes %v, want option b as %s with 2 votes", poll.Options, poll.Votes, b)
+	}
+	stored, _ := mr.Get(key)
+	if isLegacyPoll([]byte(stored)) {
+		t.Fatalf("poll is still stored as legacy: %s", stored)
+	}
+	ballots, err := r.ListBallots(context.Background(), pollID.String())
+	if err != nil {
+		t.Fatalf("failed to list ballots: %v", err)
+	}
+	if len(ballots["u1"]) != 1 || ballots["u1"][0] != b || len(ballots["u2"]) != 1 || ballots["u2"][0] != b {
+		t.Fatalf("got ballots %v, want both voters on %s", ballots, b)
+	}
+}
+
+func TestListPollsWalksLargeSelections(t *testing.T) {
+	r, _ := newTestRepo(t)
+	epoch := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	polls := make([]models.Poll, maxSelectionSize+100)
+	for i := range polls {
+		polls[i] = models.Poll{
+			ID:        uuid.New(),
+			Question:  "Q?",
+			Options:   []models.Option{{ID: "a", Label: "A"}},
+			Status:    models.StatusOpen,
+			CreatedAt: epoch.Add(time.Duration(i/10) * time.Minute),
+		}
+		if i%50 == 0 {
+			polls[i].Status = models.StatusDraft
+		}
+	}
+	if err := r.CreatePolls(context.Background(), polls); err != nil {
+		t.Fatalf("failed to create polls: %v", err)
+	}
+
+	after := epoch.Add(time.Minute)
+	tests := []struct {
+		name  string
+		query models.PollQuery
+		want  int
+	}{
+		{"filtered", models.PollQuery{Status: models.StatusOpen}, len(polls) - len(polls)/50},
+		{"by votes created after", models.PollQuery{Sort: models.SortVotes, CreatedAfter: &after}, len(polls) - 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			query.Limit = 100
+			seen := make(map[string]bool)
+			for {
+				page, err := r.ListPolls(context.Background(), query)
+				if err != nil {
+					t.Fatalf("failed to list polls: %v", err)
+				}
+				for _, poll := range page.Polls {
+					if seen[poll.ID.String()] {
+						t.Fatalf("poll %s listed twice", poll.ID)
+					}
+					seen[poll.ID.String()] = true
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			if len(seen) != tt.want {
+				t.Fatalf("listed %d polls, want %d", len(seen), tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,55 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"poll/models"
+	"time"
 )
 
-type RedisRepo interface {
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrAlreadyVoted    = errors.New("user has already voted")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// Repository stores polls with their votes. Every implementation must pass
+// the conformance suite in poll/repo/repotest.
+//
+// Writes of a poll's details are compare-and-set on its version: CreatePoll
+// stores the poll at version 1, and UpdatePoll only succeeds while the
+// stored version equals poll.Version, storing the next one. Otherwise they
+// fail with ErrVersionConflict.
+type Repository interface {
 	CreatePoll(ctx context.Context, pollID string, poll models.Poll) error
+	// CreatePolls stores the polls under their IDs in one transaction, so
+	// either all of them are created or none is.
+	CreatePolls(ctx context.Context, polls []models.Poll) error
 	GetPoll(ctx context.Context, pollID string) (*models.Poll, error)
-	ListPolls(ctx context.Context) ([]models.Poll, error)
-	DeletePoll(ctx context.Context, pollID string) error
+	ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error)
+	// DeletePoll deletes the poll if it is at the given version, or at any
+	// version if that is 0.
+	DeletePoll(ctx context.Context, pollID string, version int64) error
 	UpdatePoll(ctx context.Context, pollID string, poll models.Poll) error
+	// RecordVote stores the ballot, replacing the voter's previous one if
+	// allowChange is set, and appends the event to the poll's vote event log
+	// in the same transaction, so that the log holds every accepted ballot
+	// and nothing else. It returns the poll's vote counts.
+	RecordVote(ctx context.Context, pollID string, ballot models.Ballot, allowChange bool, event models.VoteEvent) (map[string]int, error)
+	// ListBallots returns the current choices of every voter, keyed by user ID.
+	ListBallots(ctx context.Context, pollID string) (map[string][]string, error)
+	// HasVoted reports whether the user has a ballot in the poll.
+	HasVoted(ctx context.Context, pollID, userID string) (bool, error)
+	ReplaceVotes(ctx context.Context, pollID string, votes map[string]int, ballots map[string][]string) error
+	// DueScheduledPolls returns IDs of polls whose next scheduled status
+	// change (see models.Poll.NextTransition) is at or before now.
+	DueScheduledPolls(ctx context.Context, now time.Time) ([]string, error)
 	Close() error
 }
+
+// VoteEventLog is an append-only log of accepted votes, used to audit and
+// rebuild vote counts.
+type VoteEventLog interface {
+	AppendVoteEvent(ctx context.Context, event models.VoteEvent) error
+	ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error)
+}
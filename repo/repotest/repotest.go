@@ -0,0 +1,735 @@
+// Package repotest is the conformance suite for repo.Repository
+// implementations. Call Run from a test of the implementation's package:
+//
+//	func TestRepository(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repo.Repository {
+//			return memory.New()
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"poll/models"
+	"poll/repo"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty repository for a single test.
+type Factory func(t *testing.T) repo.Repository
+
+// Run checks that the repositories returned by newRepo behave as callers of
+// repo.Repository expect. Repositories that also implement repo.VoteEventLog
+// have their event log checked as well.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r repo.Repository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreatePolls", testCreatePolls},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateOptions", testUpdateOptions},
+		{"Versions", testVersions},
+		{"Delete", testDelete},
+		{"RecordVote", testRecordVote},
+		{"RecordRankedVote", testRecordRankedVote},
+		{"ReplaceVotes", testReplaceVotes},
+		{"DueScheduledPolls", testDueScheduledPolls},
+		{"ListPollsPages", testListPollsPages},
+		{"ListPollsFilters", testListPollsFilters},
+		{"ListPollsSortByVotes", testListPollsSortByVotes},
+		{"ListPollsTies", testListPollsTies},
+		{"ListPollsInvalidCursor", testListPollsInvalidCursor},
+		{"VoteEventLog", testVoteEventLog},
+		{"RecordVoteEvents", testRecordVoteEvents},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRepo(t)
+			t.Cleanup(func() {
+				if err := r.Close(); err != nil {
+					t.Errorf("failed to close repository: %v", err)
+				}
+			})
+			tt.fn(t, r)
+		})
+	}
+}
+
+var epoch = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func newPoll(question string, created time.Time) models.Poll {
+	return models.Poll{
+		ID:       uuid.New(),
+		Question: question,
+		Options: []models.Option{
+			{ID: "a", Label: "Apple", Order: 0},
+			{ID: "b", Label: "Banana", Order: 1},
+			{ID: "c", Label: "Cherry", Order: 2},
+		},
+		Votes:             map[string]int{},
+		Status:            models.StatusOpen,
+		BallotType:        models.BallotSingle,
+		CreatedAt:         created,
+		ResultsVisibility: models.ResultsAlways,
+	}
+}
+
+func create(t *testing.T, r repo.Repository, poll models.Poll) models.Poll {
+	t.Helper()
+	if err := r.CreatePoll(context.Background(), poll.ID.String(), poll); err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	poll.Version = 1
+	return poll
+}
+
+func get(t *testing.T, r repo.Repository, pollID string) *models.Poll {
+	t.Helper()
+	poll, err := r.GetPoll(context.Background(), pollID)
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	return poll
+}
+
+// voteEvent returns the event logged for the ballot.
+func voteEvent(pollID string, ballot models.Ballot) models.VoteEvent {
+	return models.VoteEvent{PollID: pollID, UserID: ballot.UserID, Options: ballot.Choices, Timestamp: epoch}
+}
+
+func vote(t *testing.T, r repo.Repository, pollID, userID string, allowChange bool, choices ...string) map[string]int {
+	t.Helper()
+	ballot := models.Ballot{UserID: userID, Choices: choices}
+	votes, err := r.RecordVote(context.Background(), pollID, ballot, allowChange, voteEvent(pollID, ballot))
+	if err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+	return votes
+}
+
+func expectVotes(t *testing.T, got, want map[string]int) {
+	t.Helper()
+	for option, count := range want {
+		if got[option] != count {
+			t.Fatalf("votes = %v, want %v", got, want)
+		}
+	}
+	for option, count := range got {
+		if count != 0 && want[option] != count {
+			t.Fatalf("votes = %v, want %v", got, want)
+		}
+	}
+}
+
+// list walks every page of the query and returns the questions in order.
+func list(t *testing.T, r repo.Repository, query models.PollQuery) []string {
+	t.Helper()
+	var questions []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("ListPolls did not finish paging")
+		}
+		page, err := r.ListPolls(context.Background(), query)
+		if err != nil {
+			t.Fatalf("ListPolls: %v", err)
+		}
+		if query.Limit > 0 && len(page.Polls) > query.Limit {
+			t.Fatalf("ListPolls returned %d polls, limit is %d", len(page.Polls), query.Limit)
+		}
+		for _, poll := range page.Polls {
+			questions = append(questions, poll.Question)
+		}
+		if page.NextCursor == "" {
+			return questions
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func expectList(t *testing.T, r repo.Repository, query models.PollQuery, want ...string) {
+	t.Helper()
+	got := list(t, r, query)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ListPolls(%+v) = %q, want %q", query, got, want)
+	}
+}
+
+func testCreateAndGet(t *testing.T, r repo.Repository) {
+	opensAt := epoch.Add(time.Hour)
+	poll := newPoll("Favourite fruit?", epoch)
+	poll.Status = models.StatusDraft
+	poll.OpensAt = &opensAt
+	poll.CreatedBy = "alice"
+	poll.Tags = []string{"food"}
+	poll.MaxChoices = 2
+	poll.ResultsVisibility = models.ResultsAfterClose
+	create(t, r, poll)
+
+	got := get(t, r, poll.ID.String())
+	if got.ID != poll.ID || got.Question != poll.Question || got.Status != poll.Status ||
+		got.CreatedBy != poll.CreatedBy || got.MaxChoices != poll.MaxChoices || !got.CreatedAt.Equal(poll.CreatedAt) ||
+		got.ResultsVisibility != poll.ResultsVisibility {
+		t.Fatalf("GetPoll = %+v, want %+v", got, poll)
+	}
+	if len(got.Options) != 3 || got.Options[1] != poll.Options[1] {
+		t.Fatalf("GetPoll options = %+v, want %+v", got.Options, poll.Options)
+	}
+	if got.OpensAt == nil || !got.OpensAt.Equal(opensAt) {
+		t.Fatalf("GetPoll opens_at = %v, want %v", got.OpensAt, opensAt)
+	}
+	if fmt.Sprint(got.Tags) != fmt.Sprint(poll.Tags) {
+		t.Fatalf("GetPoll tags = %v, want %v", got.Tags, poll.Tags)
+	}
+	if got.Votes == nil {
+		t.Fatalf("GetPoll votes are nil")
+	}
+
+	got.Options[0].Label = "changed"
+	if again := get(t, r, poll.ID.String()); again.Options[0].Label != "Apple" {
+		t.Fatalf("GetPoll returned a poll sharing state with the repository")
+	}
+}
+
+func testCreatePolls(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	closesAt := epoch.Add(time.Hour)
+
+	first := newPoll("Favourite fruit?", epoch)
+	first.Tags = []string{"food"}
+	first.ClosesAt = &closesAt
+	second := newPoll("Favourite colour?", epoch.Add(time.Minute))
+	if err := r.CreatePolls(ctx, []models.Poll{first, second}); err != nil {
+		t.Fatalf("CreatePolls: %v", err)
+	}
+	if err := r.CreatePolls(ctx, nil); err != nil {
+		t.Fatalf("CreatePolls without polls: %v", err)
+	}
+
+	for _, poll := range []models.Poll{first, second} {
+		got := get(t, r, poll.ID.String())
+		if got.Question != poll.Question || got.Version != 1 || len(got.Options) != 3 {
+			t.Fatalf("GetPoll = %+v, want %+v at version 1", got, poll)
+		}
+	}
+	expectList(t, r, models.PollQuery{Limit: 10}, second.Question, first.Question)
+	expectList(t, r, models.PollQuery{Limit: 10, Tags: []string{"food"}}, first.Question)
+
+	pollIDs, err := r.DueScheduledPolls(ctx, closesAt)
+	if err != nil {
+		t.Fatalf("DueScheduledPolls: %v", err)
+	}
+	if len(pollIDs) != 1 || pollIDs[0] != first.ID.String() {
+		t.Fatalf("DueScheduledPolls at closes_at = %v, want the first poll", pollIDs)
+	}
+}
+
+func testGetMissing(t *testing.T, r repo.Repository) {
+	if _, err := r.GetPoll(context.Background(), uuid.NewString()); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("GetPoll of a missing poll: got %v, want repo.ErrNotFound", err)
+	}
+	if _, err := r.GetPoll(context.Background(), "not-a-uuid"); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("GetPoll of a malformed ID: got %v, want repo.ErrNotFound", err)
+	}
+	if err := r.UpdatePoll(context.Background(), uuid.NewString(), models.Poll{}); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("UpdatePoll of a missing poll: got %v, want repo.ErrNotFound", err)
+	}
+}
+
+func testUpdate(t *testing.T, r repo.Repository) {
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	vote(t, r, poll.ID.String(), "u1", false, "a")
+
+	poll.Question = "Favourite berry?"
+	poll.Status = models.StatusClosed
+	poll.ResultsVisibility = models.ResultsOwnerOnly
+	if err := r.UpdatePoll(context.Background(), poll.ID.String(), poll); err != nil {
+		t.Fatalf("UpdatePoll: %v", err)
+	}
+
+	got := get(t, r, poll.ID.String())
+	if got.Question != poll.Question || got.Status != poll.Status || got.ResultsVisibility != poll.ResultsVisibility {
+		t.Fatalf("GetPoll after update = %+v", got)
+	}
+	expectVotes(t, got.Votes, map[string]int{"a": 1})
+	expectList(t, r, models.PollQuery{Status: models.StatusOpen})
+	expectList(t, r, models.PollQuery{Status: models.StatusClosed}, "Favourite berry?")
+	expectList(t, r, models.PollQuery{Search: "fruit"})
+	expectList(t, r, models.PollQuery{Search: "berry"}, "Favourite berry?")
+}
+
+func testUpdateOptions(t *testing.T, r repo.Repository) {
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+	vote(t, r, pollID, "u1", false, "a")
+	vote(t, r, pollID, "u2", true, "b")
+	vote(t, r, pollID, "u2", true, "a")
+
+	poll.Options = []models.Option{
+		{ID: "a", Label: "Green apple", Order: 0},
+		{ID: "c", Label: "Cherry", Order: 1},
+		{ID: "d", Label: "Date", Order: 2},
+	}
+	if err := r.UpdatePoll(context.Background(), pollID, poll); err != nil {
+		t.Fatalf("UpdatePoll: %v", err)
+	}
+
+	got := get(t, r, pollID)
+	if len(got.Options) != 3 || got.Options[0].Label != "Green apple" || got.Options[2].ID != "d" {
+		t.Fatalf("options after update = %+v", got.Options)
+	}
+	expectVotes(t, got.Votes, map[string]int{"a": 2})
+	if _, ok := got.Votes["b"]; ok {
+		t.Fatalf("votes after removing option b = %v", got.Votes)
+	}
+}
+
+func testVersions(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+	if got := get(t, r, pollID); got.Version != 1 {
+		t.Fatalf("version after create = %d, want 1", got.Version)
+	}
+
+	vote(t, r, pollID, "u1", false, "a")
+	poll.Question = "Favourite berry?"
+	if err := r.UpdatePoll(ctx, pollID, poll); err != nil {
+		t.Fatalf("UpdatePoll at the current version: %v", err)
+	}
+	if got := get(t, r, pollID); got.Version != 2 {
+		t.Fatalf("version after update = %d, want 2", got.Version)
+	}
+
+	poll.Question = "Favourite nut?"
+	if err := r.UpdatePoll(ctx, pollID, poll); !errors.Is(err, repo.ErrVersionConflict) {
+		t.Fatalf("UpdatePoll at a stale version: got %v, want repo.ErrVersionConflict", err)
+	}
+	if got := get(t, r, pollID); got.Question != "Favourite berry?" {
+		t.Fatalf("question after a conflicting update = %q", got.Question)
+	}
+
+	if err := r.DeletePoll(ctx, pollID, 1); !errors.Is(err, repo.ErrVersionConflict) {
+		t.Fatalf("DeletePoll at a stale version: got %v, want repo.ErrVersionConflict", err)
+	}
+	if err := r.DeletePoll(ctx, pollID, 2); err != nil {
+		t.Fatalf("DeletePoll at the current version: %v", err)
+	}
+	if err := r.DeletePoll(ctx, pollID, 2); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("DeletePoll of a deleted poll at a version: got %v, want repo.ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, r repo.Repository) {
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	vote(t, r, poll.ID.String(), "u1", false, "a")
+
+	if err := r.DeletePoll(context.Background(), poll.ID.String(), 0); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+	if _, err := r.GetPoll(context.Background(), poll.ID.String()); err == nil {
+		t.Fatalf("GetPoll of a deleted poll succeeded")
+	}
+	expectList(t, r, models.PollQuery{})
+	expectList(t, r, models.PollQuery{Search: "fruit"})
+}
+
+func testRecordVote(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+
+	expectVotes(t, vote(t, r, pollID, "u1", false, "a"), map[string]int{"a": 1})
+	expectVotes(t, vote(t, r, pollID, "u2", false, "a", "b"), map[string]int{"a": 2, "b": 1})
+
+	repeated := models.Ballot{UserID: "u1", Choices: []string{"b"}}
+	_, err := r.RecordVote(ctx, pollID, repeated, false, voteEvent(pollID, repeated))
+	if !errors.Is(err, repo.ErrAlreadyVoted) {
+		t.Fatalf("repeated RecordVote error = %v, want %v", err, repo.ErrAlreadyVoted)
+	}
+
+	expectVotes(t, vote(t, r, pollID, "u1", true, "c"), map[string]int{"a": 1, "b": 1, "c": 1})
+	expectVotes(t, vote(t, r, pollID, "u1", true, "c"), map[string]int{"a": 1, "b": 1, "c": 1})
+	expectVotes(t, get(t, r, pollID).Votes, map[string]int{"a": 1, "b": 1, "c": 1})
+
+	ballots, err := r.ListBallots(ctx, pollID)
+	if err != nil {
+		t.Fatalf("ListBallots: %v", err)
+	}
+	want := map[string][]string{"u1": {"c"}, "u2": {"a", "b"}}
+	if fmt.Sprint(ballots) != fmt.Sprint(want) {
+		t.Fatalf("ListBallots = %v, want %v", ballots, want)
+	}
+
+	for userID, want := range map[string]bool{"u1": true, "u3": false} {
+		if voted, err := r.HasVoted(ctx, pollID, userID); err != nil || voted != want {
+			t.Fatalf("HasVoted(%s) = %v, %v, want %v", userID, voted, err, want)
+		}
+	}
+	if voted, err := r.HasVoted(ctx, uuid.NewString(), "u1"); err != nil || voted {
+		t.Fatalf("HasVoted on a missing poll = %v, %v, want false", voted, err)
+	}
+
+	missingID := uuid.NewString()
+	missing := models.Ballot{UserID: "u1", Choices: []string{"a"}}
+	if _, err := r.RecordVote(ctx, missingID, missing, false, voteEvent(missingID, missing)); err == nil {
+		t.Fatalf("RecordVote on a missing poll succeeded")
+	}
+}
+
+func testRecordRankedVote(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	poll := newPoll("Favourite fruit?", epoch)
+	poll.BallotType = models.BallotRanked
+	create(t, r, poll)
+	pollID := poll.ID.String()
+
+	ballot := models.Ballot{UserID: "u1", Choices: []string{"b", "a", "c"}, Ranked: true}
+	votes, err := r.RecordVote(ctx, pollID, ballot, true, voteEvent(pollID, ballot))
+	if err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+	expectVotes(t, votes, map[string]int{"b": 1})
+
+	ballot.Choices = []string{"c", "b"}
+	votes, err = r.RecordVote(ctx, pollID, ballot, true, voteEvent(pollID, ballot))
+	if err != nil {
+		t.Fatalf("RecordVote: %v", err)
+	}
+	expectVotes(t, votes, map[string]int{"c": 1})
+
+	ballots, err := r.ListBallots(ctx, pollID)
+	if err != nil {
+		t.Fatalf("ListBallots: %v", err)
+	}
+	if fmt.Sprint(ballots["u1"]) != "[c b]" {
+		t.Fatalf("ListBallots = %v, want the full ranking", ballots)
+	}
+}
+
+func testReplaceVotes(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+	vote(t, r, pollID, "u1", false, "a")
+
+	ballots := map[string][]string{"u2": {"b"}, "u3": {"b"}}
+	if err := r.ReplaceVotes(ctx, pollID, map[string]int{"b": 2}, ballots); err != nil {
+		t.Fatalf("ReplaceVotes: %v", err)
+	}
+
+	expectVotes(t, get(t, r, pollID).Votes, map[string]int{"b": 2})
+	got, err := r.ListBallots(ctx, pollID)
+	if err != nil {
+		t.Fatalf("ListBallots: %v", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(ballots) {
+		t.Fatalf("ListBallots = %v, want %v", got, ballots)
+	}
+
+	if err := r.DeletePoll(ctx, pollID, 0); err != nil {
+		t.Fatalf("DeletePoll: %v", err)
+	}
+	if err := r.ReplaceVotes(ctx, pollID, map[string]int{"b": 2}, ballots); !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("ReplaceVotes on a deleted poll error = %v, want %v", err, repo.ErrNotFound)
+	}
+	if voted, err := r.HasVoted(ctx, pollID, "u2"); err != nil || voted {
+		t.Fatalf("HasVoted on a deleted poll = %v, %v, want false", voted, err)
+	}
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes})
+}
+
+func testDueScheduledPolls(t *testing.T, r repo.Repository) {
+	ctx := context.Background()
+	opensAt, closesAt := epoch.Add(time.Hour), epoch.Add(2*time.Hour)
+
+	draft := newPoll("Draft", epoch)
+	draft.Status = models.StatusDraft
+	draft.OpensAt = &opensAt
+	draft = create(t, r, draft)
+
+	open := newPoll("Open", epoch)
+	open.ClosesAt = &closesAt
+	create(t, r, open)
+
+	create(t, r, newPoll("Unscheduled", epoch))
+
+	due := func(now time.Time) []string {
+		t.Helper()
+		pollIDs, err := r.DueScheduledPolls(ctx, now)
+		if err != nil {
+			t.Fatalf("DueScheduledPolls: %v", err)
+		}
+		sort.Strings(pollIDs)
+		return pollIDs
+	}
+
+	if got := due(epoch); len(got) != 0 {
+		t.Fatalf("DueScheduledPolls before any schedule = %v", got)
+	}
+	if got := due(opensAt); fmt.Sprint(got) != fmt.Sprint([]string{draft.ID.String()}) {
+		t.Fatalf("DueScheduledPolls at opens_at = %v, want the draft", got)
+	}
+
+	draft.Status = models.StatusOpen
+	if err := r.UpdatePoll(ctx, draft.ID.String(), draft); err != nil {
+		t.Fatalf("UpdatePoll: %v", err)
+	}
+	if got := due(opensAt); len(got) != 0 {
+		t.Fatalf("DueScheduledPolls after opening = %v", got)
+	}
+	if got := due(closesAt); len(got) != 1 || got[0] != open.ID.String() {
+		t.Fatalf("DueScheduledPolls at closes_at = %v, want the open poll", got)
+	}
+}
+
+func testListPollsPages(t *testing.T, r repo.Repository) {
+	var want []string
+	for i := 0; i < 12; i++ {
+		// Every third poll shares its creation time with the previous one,
+		// so pages have to resume in the middle of ties.
+		created := epoch.Add(time.Duration(i-i/3) * time.Minute)
+		poll := create(t, r, newPoll(fmt.Sprintf("Poll %02d", i), created))
+		want = append(want, poll.Question)
+	}
+
+	for _, limit := range []int{1, 5, 12, 20} {
+		got := list(t, r, models.PollQuery{Limit: limit, Ascending: true})
+		sorted := append([]string(nil), got...)
+		sort.Strings(sorted)
+		if len(got) != len(want) || fmt.Sprint(sorted) != fmt.Sprint(want) {
+			t.Fatalf("ListPolls with limit %d = %q, want all of %q", limit, got, want)
+		}
+
+		desc := list(t, r, models.PollQuery{Limit: limit})
+		for i := range desc {
+			if desc[i] != got[len(got)-1-i] {
+				t.Fatalf("ListPolls descending = %q, want the reverse of %q", desc, got)
+			}
+		}
+	}
+}
+
+func testListPollsFilters(t *testing.T, r repo.Repository) {
+	fruit := newPoll("Favourite fruit?", epoch)
+	fruit.CreatedBy = "alice"
+	fruit.Tags = []string{"food", "fun"}
+	create(t, r, fruit)
+
+	language := newPoll("Best programming language", epoch.Add(time.Minute))
+	language.Status = models.StatusDraft
+	language.CreatedBy = "bob"
+	language.Tags = []string{"tech"}
+	language.Options = []models.Option{{ID: "go", Label: "Go"}, {ID: "rust", Label: "Rust"}}
+	create(t, r, language)
+
+	colour := newPoll("Favourite colour", epoch.Add(2*time.Minute))
+	colour.CreatedBy = "alice"
+	colour.Tags = []string{"fun"}
+	create(t, r, colour)
+
+	after := epoch.Add(time.Minute)
+	expectList(t, r, models.PollQuery{}, "Favourite colour", "Best programming language", "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Status: models.StatusOpen}, "Favourite colour", "Favourite fruit?")
+	expectList(t, r, models.PollQuery{CreatedBy: "alice", Limit: 1}, "Favourite colour", "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Tags: []string{"fun", "food"}}, "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Tags: []string{"nothing"}})
+	expectList(t, r, models.PollQuery{CreatedAfter: &after}, "Favourite colour", "Best programming language")
+	expectList(t, r, models.PollQuery{CreatedBefore: &after}, "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Search: "FAV"}, "Favourite colour", "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Search: "fav bana"}, "Favourite colour", "Favourite fruit?")
+	expectList(t, r, models.PollQuery{Search: "rust"}, "Best programming language")
+	expectList(t, r, models.PollQuery{Search: "fruit colour"})
+	expectList(t, r, models.PollQuery{Status: models.StatusOpen, CreatedBy: "alice", Tags: []string{"fun"}, Search: "col", CreatedAfter: &after},
+		"Favourite colour")
+}
+
+func testListPollsSortByVotes(t *testing.T, r repo.Repository) {
+	quiet := create(t, r, newPoll("Quiet", epoch))
+	busy := create(t, r, newPoll("Busy", epoch.Add(time.Minute)))
+	middle := create(t, r, newPoll("Middle", epoch.Add(2*time.Minute)))
+	wide := create(t, r, newPoll("Wide", epoch.Add(3*time.Minute)))
+
+	for i := 0; i < 3; i++ {
+		vote(t, r, busy.ID.String(), fmt.Sprintf("u%d", i), false, "a")
+	}
+	vote(t, r, middle.ID.String(), "u1", false, "a", "b")
+	// Changing a vote moves its counts rather than adding to them.
+	vote(t, r, middle.ID.String(), "u1", true, "c")
+	// Polls are sorted by total votes, not voters: one ballot may count
+	// for several options.
+	vote(t, r, wide.ID.String(), "u1", false, "a", "b")
+
+	after := epoch.Add(time.Minute)
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes, Limit: 1}, "Busy", "Wide", "Middle", "Quiet")
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes, Ascending: true}, "Quiet", "Middle", "Wide", "Busy")
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes, CreatedAfter: &after}, "Busy", "Wide", "Middle")
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes, CreatedBefore: &after, Status: models.StatusOpen}, "Quiet")
+
+	ballots := map[string][]string{"x": {"a"}, "y": {"a"}, "z": {"a"}, "w": {"a"}}
+	if err := r.ReplaceVotes(context.Background(), quiet.ID.String(), map[string]int{"a": 4}, ballots); err != nil {
+		t.Fatalf("ReplaceVotes: %v", err)
+	}
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes}, "Quiet", "Busy", "Wide", "Middle")
+
+	// Counts of a removed option no longer add to the total.
+	wide.Options = wide.Options[2:]
+	if err := r.UpdatePoll(context.Background(), wide.ID.String(), wide); err != nil {
+		t.Fatalf("UpdatePoll: %v", err)
+	}
+	expectList(t, r, models.PollQuery{Sort: models.SortVotes, CreatedAfter: &after}, "Busy", "Middle", "Wide")
+}
+
+func testListPollsTies(t *testing.T, r repo.Repository) {
+	var polls []models.Poll
+	for i := 0; i < 9; i++ {
+		// Polls tie on creation time in pairs and on voters in threes.
+		poll := newPoll(fmt.Sprintf("Poll %d", i), epoch.Add(time.Duration(i/2)*time.Minute))
+		if i%4 == 3 {
+			poll.Status = models.StatusDraft
+		}
+		polls = append(polls, create(t, r, poll))
+		for j := 0; j < i%3; j++ {
+			vote(t, r, poll.ID.String(), fmt.Sprintf("u%d", j), false, "a")
+		}
+	}
+
+	after := epoch.Add(time.Minute)
+	for _, query := range []models.PollQuery{
+		{},
+		{Sort: models.SortVotes},
+		{Sort: models.SortVotes, Status: models.StatusOpen},
+		{Sort: models.SortVotes, CreatedAfter: &after},
+		{Status: models.StatusOpen, CreatedAfter: &after},
+	} {
+		query.Ascending = true
+		all := list(t, r, query)
+		if len(all) == 0 || len(all) > len(polls) {
+			t.Fatalf("ListPolls(%+v) = %q, want some of the polls", query, all)
+		}
+		for _, limit := range []int{1, 2, 4} {
+			query.Limit = limit
+			query.Ascending = true
+			if got := list(t, r, query); fmt.Sprint(got) != fmt.Sprint(all) {
+				t.Fatalf("ListPolls(%+v) = %q, want %q", query, got, all)
+			}
+
+			query.Ascending = false
+			desc := list(t, r, query)
+			for i := range desc {
+				if len(desc) != len(all) || desc[i] != all[len(all)-1-i] {
+					t.Fatalf("ListPolls(%+v) = %q, want the reverse of %q", query, desc, all)
+				}
+			}
+		}
+	}
+}
+
+func testListPollsInvalidCursor(t *testing.T, r repo.Repository) {
+	create(t, r, newPoll("Favourite fruit?", epoch))
+	_, err := r.ListPolls(context.Background(), models.PollQuery{Cursor: "not a cursor"})
+	if !errors.Is(err, repo.ErrInvalidCursor) {
+		t.Fatalf("ListPolls with an invalid cursor error = %v, want %v", err, repo.ErrInvalidCursor)
+	}
+}
+
+func testVoteEventLog(t *testing.T, r repo.Repository) {
+	log, ok := r.(repo.VoteEventLog)
+	if !ok {
+		t.Skip("repository does not implement repo.VoteEventLog")
+	}
+
+	ctx := context.Background()
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+
+	events := []models.VoteEvent{
+		{PollID: pollID, UserID: "u1", Option: "a", Timestamp: epoch, ClientIP: "10.0.0.1", UserAgent: "test"},
+		{PollID: pollID, UserID: "u2", Options: []string{"b", "c"}, Timestamp: epoch.Add(time.Second)},
+	}
+	for _, event := range events {
+		if err := log.AppendVoteEvent(ctx, event); err != nil {
+			t.Fatalf("AppendVoteEvent: %v", err)
+		}
+	}
+
+	got, err := log.ListVoteEvents(ctx, pollID)
+	if err != nil {
+		t.Fatalf("ListVoteEvents: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("ListVoteEvents returned %d events, want %d", len(got), len(events))
+	}
+	for i, event := range got {
+		if event.ID == "" {
+			t.Fatalf("event %d has no ID", i)
+		}
+		want := events[i]
+		if event.UserID != want.UserID || event.Option != want.Option || fmt.Sprint(event.Options) != fmt.Sprint(want.Options) ||
+			!event.Timestamp.Equal(want.Timestamp) || event.ClientIP != want.ClientIP || event.UserAgent != want.UserAgent {
+			t.Fatalf("event %d = %+v, want %+v", i, event, want)
+		}
+	}
+
+	other, err := log.ListVoteEvents(ctx, uuid.NewString())
+	if err != nil {
+		t.Fatalf("ListVoteEvents: %v", err)
+	}
+	if len(other) != 0 {
+		t.Fatalf("ListVoteEvents of another poll = %v", other)
+	}
+}
+
+// testRecordVoteEvents checks that RecordVote logs exactly the votes it
+// accepts.
+func testRecordVoteEvents(t *testing.T, r repo.Repository) {
+	log, ok := r.(repo.VoteEventLog)
+	if !ok {
+		t.Skip("repository does not implement repo.VoteEventLog")
+	}
+
+	ctx := context.Background()
+	poll := create(t, r, newPoll("Favourite fruit?", epoch))
+	pollID := poll.ID.String()
+
+	vote(t, r, pollID, "u1", false, "a")
+	rejected := models.Ballot{UserID: "u1", Choices: []string{"b"}}
+	if _, err := r.RecordVote(ctx, pollID, rejected, false, voteEvent(pollID, rejected)); !errors.Is(err, repo.ErrAlreadyVoted) {
+		t.Fatalf("repeated RecordVote error = %v, want %v", err, repo.ErrAlreadyVoted)
+	}
+	vote(t, r, pollID, "u1", true, "c")
+	vote(t, r, pollID, "u1", true, "c")
+	vote(t, r, pollID, "u2", false, "b", "a")
+
+	missingID := uuid.NewString()
+	missing := models.Ballot{UserID: "u1", Choices: []string{"a"}}
+	if _, err := r.RecordVote(ctx, missingID, missing, false, voteEvent(missingID, missing)); err == nil {
+		t.Fatalf("RecordVote on a missing poll succeeded")
+	}
+
+	got, err := log.ListVoteEvents(ctx, pollID)
+	if err != nil {
+		t.Fatalf("ListVoteEvents: %v", err)
+	}
+	want := []string{"u1 [a]", "u1 [c]", "u1 [c]", "u2 [b a]"}
+	if len(got) != len(want) {
+		t.Fatalf("ListVoteEvents returned %d events, want %d", len(got), len(want))
+	}
+	for i, event := range got {
+		if s := fmt.Sprint(event.UserID, " ", event.Options); s != want[i] {
+			t.Fatalf("event %d = %s, want %s", i, s, want[i])
+		}
+	}
+
+	if other, err := log.ListVoteEvents(ctx, missingID); err != nil || len(other) != 0 {
+		t.Fatalf("ListVoteEvents of the missing poll = %v, %v, want none", other, err)
+	}
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"poll/models"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxTermLength caps the length of the word prefixes polls are searched by.
+const MaxTermLength = 20
+
+// SearchTerms returns every prefix, up to MaxTermLength, of every word of the
+// poll's question and options. A poll matches a search when it has all of the
+// search's QueryTerms.
+func SearchTerms(poll models.Poll) []string {
+	text := []string{poll.Question}
+	for _, option := range poll.Options {
+		text = append(text, option.Label, option.Description)
+	}
+
+	seen := make(map[string]struct{})
+	for _, word := range splitWords(strings.Join(text, " ")) {
+		for i := 1; i <= len(word) && i <= MaxTermLength; i++ {
+			seen[string(word[:i])] = struct{}{}
+		}
+	}
+
+	terms := make([]string, 0, len(seen))
+	for term := range seen {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// QueryTerms returns the terms a poll needs to match the search.
+func QueryTerms(search string) []string {
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, word := range splitWords(search) {
+		if len(word) > MaxTermLength {
+			word = word[:MaxTermLength]
+		}
+		if _, ok := seen[string(word)]; !ok {
+			seen[string(word)] = struct{}{}
+			terms = append(terms, string(word))
+		}
+	}
+	return terms
+}
+
+func splitWords(text string) [][]rune {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	words := make([][]rune, len(fields))
+	for i, field := range fields {
+		words[i] = []rune(field)
+	}
+	return words
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"poll/auth"
+)
+
+// authenticate puts the principal of requests carrying valid credentials
+// into their context. Requests without credentials pass through anonymously,
+// marked with the role anonymous callers have while authentication is
+// enabled; those with invalid credentials are rejected.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, authenticator := range h.authenticators {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				h.writeError(w, err)
+				return
+			}
+			if principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if len(h.authenticators) > 0 {
+			r = r.WithContext(auth.WithAnonymousRole(r.Context(), h.anonymousRole))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAuth rejects anonymous requests. It lets everything through while
+// authentication is disabled.
+func (h *Handler) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.authenticated(r) {
+			h.writeError(w, auth.ErrUnauthenticated)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated reports whether the request carries valid credentials or
+// authentication is disabled.
+func (h *Handler) authenticated(r *http.Request) bool {
+	if len(h.authenticators) == 0 {
+		return true
+	}
+	_, ok := auth.FromContext(r.Context())
+	return ok
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"poll/auth"
+	"poll/configs"
+	"testing"
+)
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	authenticators, err := auth.New(configs.AuthConfig{
+		APIKeys: map[string]string{"k1": "alice", "k2": "bob"},
+		Roles:   map[string]string{"alice": auth.RoleAdmin},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticators: %v", err)
+	}
+	ts := newTestServer(t, configs.HTTPConfig{}, authenticators, nil)
+	poll := map[string]any{"question": "Fruit?", "options": []string{"apple", "pear"}}
+	apiKey := func(key string) http.Header {
+		return http.Header{auth.APIKeyHeader: {key}}
+	}
+
+	url := createPoll(t, ts, apiKey("k1"), poll)
+	var created struct {
+		CreatedBy string `json:"created_by"`
+	}
+	if resp := request(t, "GET", url, nil, nil, &created); resp.StatusCode != http.StatusOK || created.CreatedBy != "alice" {
+		t.Fatalf("anonymous GET = %d, created by %q, want 200 and alice", resp.StatusCode, created.CreatedBy)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		header http.Header
+		want   int
+	}{
+		{"invalid key on an open route", "GET", url, apiKey("nope"), http.StatusUnauthorized},
+		{"invalid key on a protected route", "POST", ts.URL + "/polls", apiKey("nope"), http.StatusUnauthorized},
+		{"no credentials on a protected route", "POST", ts.URL + "/polls", nil, http.StatusUnauthorized},
+		{"bearer token without JWT authentication", "GET", url, http.Header{"Authorization": {"Bearer nope"}}, http.StatusOK},
+		{"valid key", "POST", ts.URL + "/polls", apiKey("k1"), http.StatusOK},
+		{"voter creating a poll", "POST", ts.URL + "/polls", apiKey("k2"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errResp ErrorResponse
+			resp := request(t, tt.method, tt.url, tt.header, poll, &errResp)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized {
+				if errResp.Code != "unauthenticated" || resp.Header.Get("WWW-Authenticate") == "" {
+					t.Errorf("got code %s and WWW-Authenticate %q, want unauthenticated with a challenge", errResp.Code, resp.Header.Get("WWW-Authenticate"))
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticationDisabled(t *testing.T) {
+	ts := newTestServer(t, configs.HTTPConfig{}, nil, nil)
+
+	resp := request(t, "POST", ts.URL+"/polls", nil, map[string]any{"question": "Fruit?", "options": []string{"apple", "pear"}}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("anonymous POST without authenticators = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAnonymousRole(t *testing.T) {
+	authenticators, err := auth.New(configs.AuthConfig{
+		APIKeys: map[string]string{"k1": "alice"},
+		Roles:   map[string]string{"alice": auth.RoleAdmin},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticators: %v", err)
+	}
+	poll := map[string]any{"question": "Fruit?", "options": []string{"apple", "pear"}, "status": "open"}
+	admin := http.Header{auth.APIKeyHeader: {"k1"}}
+
+	tests := []struct {
+		name          string
+		anonymousRole string
+		want          int
+	}{
+		{"viewer by default", "", http.StatusUnauthorized},
+		{"viewer", auth.RoleViewer, http.StatusUnauthorized},
+		{"voter", auth.RoleVoter, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, configs.HTTPConfig{Auth: configs.AuthConfig{AnonymousRole: tt.anonymousRole}}, authenticators, nil)
+			url := createPoll(t, ts, admin, poll)
+
+			var created struct {
+				Options []struct {
+					ID string `json:"id"`
+				} `json:"options"`
+			}
+			if resp := request(t, "GET", url, nil, nil, &created); resp.StatusCode != http.StatusOK {
+				t.Fatalf("anonymous GET = %d, want 200", resp.StatusCode)
+			}
+
+			var errResp ErrorResponse
+			resp := request(t, "POST", url+"/vote", nil, map[string]any{"option": created.Options[0].ID, "user_id": "u1"}, &errResp)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("anonymous vote = %d %s, want %d", resp.StatusCode, errResp.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("anonymous vote was rejected without a challenge")
+			}
+
+			if resp := request(t, "POST", url+"/vote", admin, map[string]any{"option": created.Options[0].ID}, nil); resp.StatusCode != http.StatusOK {
+				t.Fatalf("authenticated vote = %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
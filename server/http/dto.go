@@ -1,27 +1,122 @@
 package server
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
+	"poll/models"
+	"time"
 )
 
+// OptionRequest describes a poll option. A plain string is accepted as a
+// shorthand for an option with just a label. ID is only set when updating a
+// poll, to refer to one of its existing options.
+type OptionRequest struct {
+	ID          string `json:"id,omitempty"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	Order       int    `json:"order,omitempty"`
+}
+
+func (o *OptionRequest) UnmarshalJSON(data []byte) error {
+	var label string
+	if err := json.Unmarshal(data, &label); err == nil {
+		*o = OptionRequest{Label: label}
+		return nil
+	}
+
+	type plain OptionRequest
+	return json.Unmarshal(data, (*plain)(o))
+}
+
+func toOptions(reqs []OptionRequest) []models.Option {
+	options := make([]models.Option, len(reqs))
+	for i, req := range reqs {
+		options[i] = models.Option{
+			ID:          req.ID,
+			Label:       req.Label,
+			Description: req.Description,
+			ImageURL:    req.ImageURL,
+			Order:       req.Order,
+		}
+	}
+	return options
+}
+
 type CreatePollRequest struct {
-	Question string   `json:"question"`
-	Options  []string `json:"options"`
+	Question          string          `json:"question"`
+	Options           []OptionRequest `json:"options"`
+	AllowVoteChange   bool            `json:"allow_vote_change"`
+	Status            string          `json:"status" enums:"draft,open" default:"draft"`
+	OpensAt           *time.Time      `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time      `json:"closes_at,omitempty"`
+	BallotType        string          `json:"ballot_type" enums:"single,multi,ranked" default:"single"`
+	MinChoices        int             `json:"min_choices,omitempty"`
+	MaxChoices        int             `json:"max_choices,omitempty"`
+	CreatedBy         string          `json:"created_by,omitempty"`
+	Tags              []string        `json:"tags,omitempty"`
+	ResultsVisibility string          `json:"results_visibility,omitempty" enums:"always,after_vote,after_close,owner_only" default:"always"`
 }
 
 type UpdatePollRequest struct {
-	Question string   `json:"question"`
-	Options  []string `json:"options"`
+	Question          string          `json:"question"`
+	Options           []OptionRequest `json:"options"`
+	AllowVoteChange   bool            `json:"allow_vote_change"`
+	OpensAt           *time.Time      `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time      `json:"closes_at,omitempty"`
+	BallotType        string          `json:"ballot_type" enums:"single,multi,ranked" default:"single"`
+	MinChoices        int             `json:"min_choices,omitempty"`
+	MaxChoices        int             `json:"max_choices,omitempty"`
+	Tags              []string        `json:"tags,omitempty"`
+	ResultsVisibility string          `json:"results_visibility,omitempty" enums:"always,after_vote,after_close,owner_only" default:"always"`
 }
 
 type PollResponse struct {
-	ID       uuid.UUID      `json:"id"`
-	Question string         `json:"question"`
-	Options  []string       `json:"options"`
-	Votes    map[string]int `json:"votes"`
+	ID                uuid.UUID       `json:"id"`
+	Question          string          `json:"question"`
+	Options           []models.Option `json:"options"`
+	Votes             map[string]int  `json:"votes"`
+	AllowVoteChange   bool            `json:"allow_vote_change"`
+	Status            string          `json:"status"`
+	OpensAt           *time.Time      `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time      `json:"closes_at,omitempty"`
+	BallotType        string          `json:"ballot_type"`
+	MinChoices        int             `json:"min_choices,omitempty"`
+	MaxChoices        int             `json:"max_choices,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	CreatedBy         string          `json:"created_by,omitempty"`
+	Tags              []string        `json:"tags,omitempty"`
+	ResultsVisibility string          `json:"results_visibility"`
 }
 
+// VoterTokenResponse is an issued voter token and the voter ID it carries.
+type VoterTokenResponse struct {
+	Token     string    `json:"token"`
+	VoterID   string    `json:"voter_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// VoteRequest carries a single-choice vote in Option, or the picked options
+// of a multi-select poll and the ranking of a ranked poll in Options. Options
+// are referred to by ID.
 type VoteRequest struct {
-	Option string `json:"option"`
-	UserID string `json:"user_id"`
+	Option  string   `json:"option"`
+	Options []string `json:"options"`
+	UserID  string   `json:"user_id"`
+}
+
+// ImportResponse reports the outcome of importing polls. Rows are numbered
+// from 1 in file order; polls are "created", "valid" in a dry run, "skipped"
+// when an atomic import was aborted, or "failed" with the reason.
+type ImportResponse struct {
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Rows    []ImportRowResponse `json:"rows"`
+}
+
+type ImportRowResponse struct {
+	Row    int            `json:"row"`
+	Status string         `json:"status" enums:"created,valid,skipped,failed"`
+	PollID string         `json:"poll_id,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
 }
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"poll/auth"
+	"poll/ratelimit"
+	"poll/service"
+)
+
+// ErrorResponse is the body of every failed request. Code is stable and
+// meant for clients to branch on; Message is for humans. Errors lists the
+// invalid fields of a request body that failed validation.
+type ErrorResponse struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Field   string       `json:"field,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type errorKind struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorKinds maps the service's domain errors to HTTP responses. The first
+// entry the error matches wins.
+var errorKinds = []errorKind{
+	{auth.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+	{auth.ErrInvalidVoterToken, http.StatusUnauthorized, "invalid_voter_token"},
+	{service.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{service.ErrPollNotFound, http.StatusNotFound, "poll_not_found"},
+	{service.ErrAlreadyVoted, http.StatusConflict, "already_voted"},
+	{service.ErrPollClosed, http.StatusConflict, "poll_closed"},
+	{service.ErrPollLocked, http.StatusConflict, "poll_locked"},
+	{service.ErrOptionInUse, http.StatusConflict, "option_in_use"},
+	{service.ErrVersionConflict, http.StatusPreconditionFailed, "version_conflict"},
+	{service.ErrInvalidTransition, http.StatusConflict, "invalid_transition"},
+	{service.ErrInvalidStatus, http.StatusBadRequest, "invalid_status"},
+	{service.ErrInvalidSchedule, http.StatusBadRequest, "invalid_schedule"},
+	{service.ErrInvalidBallot, http.StatusBadRequest, "invalid_ballot"},
+	{service.ErrInvalidOption, http.StatusBadRequest, "invalid_option"},
+	{service.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{service.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+	{ratelimit.ErrLimited, http.StatusTooManyRequests, "rate_limited"},
+	{errNotAcceptable, http.StatusNotAcceptable, "not_acceptable"},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+}
+
+// writeError responds with the status and code matching err, see
+// errorResponse, challenging unauthenticated callers to authenticate.
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	status, resp := h.errorResponse(err)
+
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="poll"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if encodeErr := json.NewEncoder(w).Encode(resp); encodeErr != nil {
+		h.log.Printf("error encoding error response: %v", encodeErr)
+	}
+}
+
+// errorResponse returns the status and body reporting err. Errors that are
+// not part of the domain are logged and reported as internal errors without
+// leaking their details.
+func (h *Handler) errorResponse(err error) (int, ErrorResponse) {
+	status := http.StatusInternalServerError
+	resp := ErrorResponse{Code: "internal_error", Message: "internal server error"}
+
+	var validationErrs service.ValidationErrors
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErrs) {
+		status = http.StatusUnprocessableEntity
+		resp = ErrorResponse{Code: "validation_failed", Message: "request has invalid fields"}
+		for _, fieldErr := range validationErrs {
+			resp.Errors = append(resp.Errors, FieldError{Field: fieldErr.Field, Message: fieldErr.Message})
+		}
+	} else if errors.As(err, &validationErr) {
+		status = http.StatusBadRequest
+		resp = ErrorResponse{Code: "invalid_request", Message: validationErr.Message, Field: validationErr.Field}
+	} else {
+		for _, kind := range errorKinds {
+			if errors.Is(err, kind.err) {
+				status = kind.status
+				resp = ErrorResponse{Code: kind.code, Message: err.Error()}
+				break
+			}
+		}
+	}
+
+	if status == http.StatusInternalServerError {
+		h.log.Printf("internal error: %v", err)
+	}
+	return status, resp
+}
+
+// invalidField reports a malformed request field or parameter.
+func invalidField(field, message string) error {
+	return &service.ValidationError{Field: field, Message: message}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"poll/auth"
+	"poll/ratelimit"
+	"poll/service"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		resp   ErrorResponse
+	}{
+		{"unauthenticated", auth.ErrUnauthenticated, http.StatusUnauthorized, ErrorResponse{Code: "unauthenticated"}},
+		{"invalid voter token", auth.ErrInvalidVoterToken, http.StatusUnauthorized, ErrorResponse{Code: "invalid_voter_token"}},
+		{"forbidden", service.ErrForbidden, http.StatusForbidden, ErrorResponse{Code: "forbidden"}},
+		{"poll not found", service.ErrPollNotFound, http.StatusNotFound, ErrorResponse{Code: "poll_not_found"}},
+		{"already voted", service.ErrAlreadyVoted, http.StatusConflict, ErrorResponse{Code: "already_voted"}},
+		{"poll closed", service.ErrPollClosed, http.StatusConflict, ErrorResponse{Code: "poll_closed"}},
+		{"poll locked", service.ErrPollLocked, http.StatusConflict, ErrorResponse{Code: "poll_locked"}},
+		{"option in use", service.ErrOptionInUse, http.StatusConflict, ErrorResponse{Code: "option_in_use"}},
+		{"version conflict", service.ErrVersionConflict, http.StatusPreconditionFailed, ErrorResponse{Code: "version_conflict"}},
+		{"invalid transition", service.ErrInvalidTransition, http.StatusConflict, ErrorResponse{Code: "invalid_transition"}},
+		{"invalid status", service.ErrInvalidStatus, http.StatusBadRequest, ErrorResponse{Code: "invalid_status"}},
+		{"invalid schedule", service.ErrInvalidSchedule, http.StatusBadRequest, ErrorResponse{Code: "invalid_schedule"}},
+		{"invalid ballot", service.ErrInvalidBallot, http.StatusBadRequest, ErrorResponse{Code: "invalid_ballot"}},
+		{"invalid option", service.ErrInvalidOption, http.StatusBadRequest, ErrorResponse{Code: "invalid_option"}},
+		{"invalid cursor", service.ErrInvalidCursor, http.StatusBadRequest, ErrorResponse{Code: "invalid_cursor"}},
+		{"invalid query", service.ErrInvalidQuery, http.StatusBadRequest, ErrorResponse{Code: "invalid_query"}},
+		{"rate limited", ratelimit.ErrLimited, http.StatusTooManyRequests, ErrorResponse{Code: "rate_limited"}},
+		{"not acceptable", errNotAcceptable, http.StatusNotAcceptable, ErrorResponse{Code: "not_acceptable"}},
+		{"unsupported media type", errUnsupportedMediaType, http.StatusUnsupportedMediaType, ErrorResponse{Code: "unsupported_media_type"}},
+		{
+			name:   "unauthenticated before forbidden",
+			err:    errors.Join(service.ErrForbidden, auth.ErrUnauthenticated),
+			status: http.StatusUnauthorized,
+			resp:   ErrorResponse{Code: "unauthenticated"},
+		},
+		{
+			name:   "forbidden before not found",
+			err:    errors.Join(service.ErrPollNotFound, service.ErrForbidden),
+			status: http.StatusForbidden,
+			resp:   ErrorResponse{Code: "forbidden"},
+		},
+		{
+			name:   "invalid field",
+			err:    invalidField("limit", "must be a number"),
+			status: http.StatusBadRequest,
+			resp:   ErrorResponse{Code: "invalid_request", Message: "must be a number", Field: "limit"},
+		},
+		{
+			name: "invalid fields",
+			err: service.ValidationErrors{
+				{Field: "question", Message: "is required"},
+				{Field: "options", Message: "must have at least 2 options"},
+			},
+			status: http.StatusUnprocessableEntity,
+			resp: ErrorResponse{Code: "validation_failed", Message: "request has invalid fields", Errors: []FieldError{
+				{Field: "question", Message: "is required"},
+				{Field: "options", Message: "must have at least 2 options"},
+			}},
+		},
+		{
+			name:   "validation before domain errors",
+			err:    errors.Join(service.ErrPollNotFound, invalidField("id", "must be a UUID")),
+			status: http.StatusBadRequest,
+			resp:   ErrorResponse{Code: "invalid_request", Message: "must be a UUID", Field: "id"},
+		},
+		{
+			name:   "internal",
+			err:    errors.New("connection refused by 10.0.0.7"),
+			status: http.StatusInternalServerError,
+			resp:   ErrorResponse{Code: "internal_error", Message: "internal server error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			h := &Handler{log: log.New(&logs, "", 0)}
+
+			err := fmt.Errorf("failed to do it: %w", tt.err)
+			status, resp := h.errorResponse(err)
+			if tt.resp.Message == "" {
+				tt.resp.Message = err.Error()
+			}
+			if status != tt.status || !reflect.DeepEqual(resp, tt.resp) {
+				t.Errorf("got %d %+v, want %d %+v", status, resp, tt.status, tt.resp)
+			}
+
+			if logged := strings.Contains(logs.String(), tt.err.Error()); logged != (tt.status == http.StatusInternalServerError) {
+				t.Errorf("logged %q for a %d response", logs.String(), status)
+			}
+		})
+	}
+
+	// Every error kind must be covered above.
+	for _, kind := range errorKinds {
+		found := false
+		for _, tt := range tests {
+			found = found || (tt.err == kind.err && tt.resp.Code == kind.code)
+		}
+		if !found {
+			t.Errorf("error kind %s is not tested", kind.code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"poll/service"
+	"strconv"
+	"strings"
+)
+
+// etag is the entity tag of a poll at the given version. It is weak: the
+// version only changes with the poll's details, while the body also holds
+// vote counts and is redacted for some callers.
+func etag(version int64) string {
+	return "W/" + strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ifMatchVersion returns the poll version the request's If-Match header
+// requires, or 0 if it accepts any. Since polls only have weak tags, they
+// are compared weakly, so W/"3" and "3" both name version 3; tags that are
+// not poll versions never match.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	if strings.Contains(value, ",") {
+		return 0, invalidField("If-Match", "only one entity tag is supported")
+	}
+
+	tag := strings.TrimPrefix(value, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, invalidField("If-Match", "must be an entity tag")
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: entity tag %s is not a poll version", service.ErrVersionConflict, value)
+	}
+	return version, nil
+}
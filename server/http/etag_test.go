@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"poll/configs"
+	"poll/service"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		want      int64
+		wantErr   error
+		wantField string
+	}{
+		{name: "absent", value: "", want: 0},
+		{name: "any", value: "*", want: 0},
+		{name: "strong", value: `"3"`, want: 3},
+		{name: "weak", value: `W/"3"`, want: 3},
+		{name: "surrounding spaces", value: ` "12" `, want: 12},
+		{name: "not a version", value: `"abc"`, wantErr: service.ErrVersionConflict},
+		{name: "version 0", value: `"0"`, wantErr: service.ErrVersionConflict},
+		{name: "negative version", value: `"-1"`, wantErr: service.ErrVersionConflict},
+		{name: "list", value: `"1", "2"`, wantField: "If-Match"},
+		{name: "list with any", value: `*, "2"`, wantField: "If-Match"},
+		{name: "unquoted", value: `3`, wantField: "If-Match"},
+		{name: "weak unquoted", value: `W/3`, wantField: "If-Match"},
+		{name: "unterminated", value: `"3`, wantField: "If-Match"},
+		{name: "lowercase weak prefix", value: `w/"3"`, wantField: "If-Match"},
+		{name: "single quotes", value: `'3'`, wantField: "If-Match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/polls/x", nil)
+			if tt.value != "" {
+				r.Header.Set("If-Match", tt.value)
+			}
+
+			got, err := ifMatchVersion(r)
+			switch {
+			case tt.wantField != "":
+				if f := fields(err); len(f) != 1 || f[0] != tt.wantField {
+					t.Fatalf("error = %v, want an invalid %s", err, tt.wantField)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case got != tt.want:
+				t.Errorf("version = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	if got := etag(7); got != `W/"7"` {
+		t.Fatalf("etag(7) = %s, want W/\"7\"", got)
+	}
+}
+
+func TestIfMatchPreconditions(t *testing.T) {
+	ts := newTestServer(t, configs.HTTPConfig{}, nil, nil)
+	url := createPoll(t, ts, nil, map[string]any{"question": "Fruit?", "options": []string{"apple", "pear"}})
+	ifMatch := func(tag string) http.Header {
+		return http.Header{"If-Match": {tag}}
+	}
+	update := map[string]any{"question": "Fruits?", "options": []string{"apple", "pear"}}
+
+	resp := request(t, "GET", url, nil, nil, nil)
+	if tag := resp.Header.Get("ETag"); tag != `W/"1"` {
+		t.Fatalf("ETag = %s, want W/\"1\"", tag)
+	}
+
+	resp = request(t, "PUT", url, ifMatch(`W/"1"`), update, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `W/"2"` {
+		t.Fatalf("PUT with the current tag = %d, ETag %s, want 200, W/\"2\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	var errResp ErrorResponse
+	resp = request(t, "PUT", url, ifMatch(`W/"1"`), update, &errResp)
+	if resp.StatusCode != http.StatusPreconditionFailed || errResp.Code != "version_conflict" {
+		t.Fatalf("PUT with a stale tag = %d %s, want 412 version_conflict", resp.StatusCode, errResp.Code)
+	}
+
+	resp = request(t, "PATCH", url, ifMatch(`"1"`), map[string]any{"question": "Pears?"}, nil)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH with a stale tag = %d, want 412", resp.StatusCode)
+	}
+	resp = request(t, "PATCH", url, ifMatch(`"2"`), map[string]any{"question": "Pears?"}, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `W/"3"` {
+		t.Fatalf("PATCH with the current strong tag = %d, ETag %s, want 200, W/\"3\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	resp = request(t, "DELETE", url, ifMatch(`"not-a-version"`), nil, nil)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with a foreign tag = %d, want 412", resp.StatusCode)
+	}
+	resp = request(t, "DELETE", url, ifMatch(`3`), nil, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("DELETE with a malformed tag = %d, want 400", resp.StatusCode)
+	}
+
+	var poll struct {
+		Question string `json:"question"`
+	}
+	resp = request(t, "GET", url, nil, nil, &poll)
+	if resp.StatusCode != http.StatusOK || poll.Question != "Pears?" {
+		t.Fatalf("poll after failed preconditions = %d %q, want it unchanged", resp.StatusCode, poll.Question)
+	}
+
+	resp = request(t, "DELETE", url, ifMatch("*"), nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE with any tag = %d, want 200", resp.StatusCode)
+	}
+}
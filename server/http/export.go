@@ -0,0 +1,254 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"poll/auth"
+	"poll/export"
+	"poll/models"
+	"strconv"
+	"strings"
+)
+
+var errNotAcceptable = errors.New("none of the accepted media types is exported")
+
+// @Tags Polls
+// @Summary Export the results of a poll
+// @Description Download a poll's results as CSV, NDJSON or XLSX, chosen by the format parameter or else the Accept header. Vote counts are left out while the poll's results visibility hides them. With votes set, the poll's vote events follow, which takes permission to list them.
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param id path string true "Poll ID"
+// @Param format query string false "File format, overriding the Accept header" Enums(csv, ndjson, xlsx)
+// @Param votes query bool false "Include the poll's vote events"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 406 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/results [get]
+func (h *Handler) GetResults(w http.ResponseWriter, r *http.Request) {
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	format, votes, err := h.exportParams(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	results, err := h.srv.GetResults(r.Context(), pollID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	var events []models.VoteEvent
+	if votes {
+		events, err = h.srv.ListVoteEvents(r.Context(), pollID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+	}
+
+	out, err := startExport(w, format, "poll-"+pollID+"-results")
+	if err == nil {
+		err = out.Write(*results, events)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		h.log.Printf("failed to export results of poll %s: %v", pollID, err)
+	}
+}
+
+// @Tags Polls
+// @Summary Export the results of polls
+// @Description Download the results of every poll matching the filters of GET /polls as CSV, NDJSON or XLSX, chosen by the format parameter or else the Accept header. The file is streamed as polls are loaded; vote counts are left out of polls whose results visibility hides them. With votes set, every poll whose vote events the caller may list is followed by them.
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param status query string false "Only polls with this status" Enums(draft, open, closed, archived)
+// @Param creator query string false "Only polls created by this user"
+// @Param tag query []string false "Only polls carrying all of these tags" collectionFormat(multi)
+// @Param created_after query string false "Only polls created at or after this time (RFC 3339)"
+// @Param created_before query string false "Only polls created before this time (RFC 3339)"
+// @Param q query string false "Only polls with words in the question or options starting with each word of this text"
+// @Param sort query string false "Sort by creation time or total votes" Enums(created, votes) default(created)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(desc)
+// @Param format query string false "File format, overriding the Accept header" Enums(csv, ndjson, xlsx)
+// @Param votes query bool false "Include vote events"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 406 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/export [get]
+func (h *Handler) ExportPolls(w http.ResponseWriter, r *http.Request) {
+	query, err := pollQuery(r.URL.Query())
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	format, votes, err := h.exportParams(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	// The response starts with the first poll, so errors loading that one
+	// can still be reported properly. Later ones cut the file short.
+	var out export.Writer
+	err = h.srv.ExportResults(r.Context(), query, votes, func(results models.PollResults, events []models.VoteEvent) error {
+		if out == nil {
+			var err error
+			if out, err = startExport(w, format, "poll-results"); err != nil {
+				return err
+			}
+		}
+		if err := out.Write(results, events); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	})
+	if err != nil && out == nil {
+		h.writeError(w, err)
+		return
+	} else if err != nil {
+		h.log.Printf("failed to export poll results: %v", err)
+		return
+	}
+
+	if out == nil {
+		if out, err = startExport(w, format, "poll-results"); err != nil {
+			h.log.Printf("failed to export poll results: %v", err)
+			return
+		}
+	}
+	if err := out.Close(); err != nil {
+		h.log.Printf("failed to export poll results: %v", err)
+	}
+}
+
+// exportParams reads the format to export results as and whether to
+// include vote events, which takes valid credentials unless authentication
+// is disabled.
+func (h *Handler) exportParams(r *http.Request) (export.Format, bool, error) {
+	format, err := exportFormat(r)
+	if err != nil {
+		return "", false, err
+	}
+
+	votes, err := boolParam(r, "votes")
+	if err != nil {
+		return "", false, err
+	}
+	if votes && !h.authenticated(r) {
+		return "", false, fmt.Errorf("%w: vote events are only exported to authenticated callers", auth.ErrUnauthenticated)
+	}
+
+	return format, votes, nil
+}
+
+// exportFormat returns the format named by the format query parameter, else
+// the exported one the Accept header prefers, else CSV. Formats are weighed
+// by the q-value of the most specific media range matching them, with ties
+// going to the one listed first; q=0 rules a format out.
+func exportFormat(r *http.Request) (export.Format, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		for _, format := range export.Formats {
+			if string(format) == value {
+				return format, nil
+			}
+		}
+		return "", invalidField("format", "must be csv, ndjson or xlsx")
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return export.CSV, nil
+	}
+
+	type weight struct {
+		specificity int
+		q           float64
+		position    int
+	}
+	weights := make(map[export.Format]weight, len(export.Formats))
+	for position, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		for _, format := range export.Formats {
+			specificity := mediaRangeSpecificity(mediaType, format.MediaType())
+			if specificity > weights[format].specificity {
+				weights[format] = weight{specificity: specificity, q: q, position: position}
+			}
+		}
+	}
+
+	var best export.Format
+	var bestWeight weight
+	for _, format := range export.Formats {
+		w, ok := weights[format]
+		if !ok || w.q == 0 {
+			continue
+		}
+		if best == "" || w.q > bestWeight.q || (w.q == bestWeight.q && w.position < bestWeight.position) {
+			best, bestWeight = format, w
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%w: %s", errNotAcceptable, accept)
+	}
+	return best, nil
+}
+
+// mediaRangeSpecificity returns how specifically the media range matches
+// the media type: 3 for the type itself, 2 for type/*, 1 for */* and 0 if
+// it does not match.
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 3
+	case mediaRange == "*/*":
+		return 1
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 2
+	default:
+		return 0
+	}
+}
+
+// startExport responds with a file of the format, named after name, and
+// returns the writer of its contents.
+func startExport(w http.ResponseWriter, format export.Format, name string) (export.Writer, error) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": name + "." + string(format),
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	return export.NewWriter(format, w)
+}
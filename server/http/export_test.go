@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"poll/export"
+	"testing"
+)
+
+func TestExportFormat(t *testing.T) {
+	const xlsx = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	tests := []struct {
+		name      string
+		query     string
+		accept    string
+		want      export.Format
+		wantErr   error
+		wantField string
+	}{
+		{name: "no header", want: export.CSV},
+		{name: "format parameter", query: "?format=xlsx", accept: "text/csv", want: export.XLSX},
+		{name: "unknown format parameter", query: "?format=pdf", wantField: "format"},
+		{name: "exact type", accept: "application/x-ndjson", want: export.NDJSON},
+		{name: "first listed wins a tie", accept: xlsx + ", application/x-ndjson", want: export.XLSX},
+		{name: "highest q wins", accept: "text/csv;q=0.1, application/x-ndjson", want: export.NDJSON},
+		{name: "q before order", accept: "application/x-ndjson;q=0.5, " + xlsx + ";q=0.8", want: export.XLSX},
+		{name: "any type", accept: "*/*", want: export.CSV},
+		{name: "text types", accept: "text/*", want: export.CSV},
+		{name: "application types", accept: "application/*", want: export.NDJSON},
+		{name: "browser default", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: export.CSV},
+		{name: "specific range overrides wildcard", accept: "*/*;q=0.5, text/csv;q=0", want: export.NDJSON},
+		{name: "specific q over wildcard q", accept: "*/*;q=0.9, text/csv;q=0.2", want: export.NDJSON},
+		{name: "excluded", accept: "text/csv;q=0", wantErr: errNotAcceptable},
+		{name: "invalid q skipped", accept: "application/x-ndjson;q=high, text/csv;q=0.5", want: export.CSV},
+		{name: "malformed range skipped", accept: "text/, application/x-ndjson", want: export.NDJSON},
+		{name: "nothing exported", accept: "application/pdf", wantErr: errNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/polls/export"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			got, err := exportFormat(r)
+			if tt.wantField != "" {
+				if f := fields(err); len(f) != 1 || f[0] != tt.wantField {
+					t.Fatalf("error = %v, want an invalid %s", err, tt.wantField)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("format = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
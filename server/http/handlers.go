@@ -2,64 +2,131 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/go-chi/chi"
-	"github.com/google/uuid"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"poll/auth"
+	"poll/configs"
 	_ "poll/docs"
 	"poll/models"
+	"poll/ratelimit"
 	"poll/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
 )
 
 type Handler struct {
-	log *log.Logger
-	srv service.PollService
+	log            *log.Logger
+	srv            service.PollService
+	limits         configs.ValidationConfig
+	rateLimits     configs.RateLimitConfig
+	voterTokenCfg  configs.VoterTokenConfig
+	authenticators []auth.Authenticator
+	anonymousRole  string
+	limiter        ratelimit.Limiter
+	voterTokens    *auth.VoterTokens
+	trustedProxies configs.Networks
 }
 
-func NewHandler(log *log.Logger, srv service.PollService) *Handler {
-	return &Handler{
-		log: log,
-		srv: srv,
+// NewHandler returns the HTTP API of the service. Without authenticators,
+// every route is open to anonymous callers; with them, anonymous callers
+// have the role cfg.Auth.AnonymousRole, a viewer's by default. Without a
+// limiter, requests are not rate limited.
+func NewHandler(log *log.Logger, srv service.PollService, cfg configs.HTTPConfig, authenticators []auth.Authenticator, limiter ratelimit.Limiter) *Handler {
+	h := &Handler{
+		log:            log,
+		srv:            srv,
+		limits:         cfg.Validation,
+		rateLimits:     cfg.RateLimit,
+		voterTokenCfg:  cfg.VoterTokens,
+		authenticators: authenticators,
+		anonymousRole:  cfg.Auth.AnonymousRole,
+		limiter:        limiter,
+		trustedProxies: cfg.TrustedProxies,
+	}
+	if h.anonymousRole == "" {
+		h.anonymousRole = auth.RoleViewer
 	}
+	if cfg.VoterTokens.Secret != "" {
+		h.voterTokens = auth.NewVoterTokens(cfg.VoterTokens.Secret, cfg.VoterTokens.TTL.Duration)
+	}
+	return h
 }
 
 func (h *Handler) RegisterRoutes(r chi.Router) {
-	r.Post("/polls", h.CreatePoll)
-	r.Get("/polls/{id}", h.GetPoll)
-	r.Put("/polls/{id}", h.UpdatePoll)
-	r.Delete("/polls/{id}", h.DeletePoll)
+	r.Use(h.authenticate, h.identifyVoter)
+
 	r.Get("/polls", h.ListPolls)
+	r.Get("/polls/export", h.ExportPolls)
+	r.Get("/polls/{id}", h.GetPoll)
+	r.Get("/polls/{id}/results", h.GetResults)
 	r.Post("/polls/{id}/vote", h.VoteHandler)
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
+	if h.voterTokens != nil {
+		r.Post("/voter-tokens", h.IssueVoterToken)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireAuth)
+
+		r.Post("/polls", h.CreatePoll)
+		r.Post("/polls/import", h.ImportPolls)
+		r.Put("/polls/{id}", h.UpdatePoll)
+		r.Patch("/polls/{id}", h.PatchPoll)
+		r.Delete("/polls/{id}", h.DeletePoll)
+		r.Post("/polls/{id}/open", h.OpenPoll)
+		r.Post("/polls/{id}/close", h.ClosePoll)
+		r.Post("/polls/{id}/archive", h.ArchivePoll)
+		r.Get("/polls/{id}/events", h.ListVoteEvents)
+		r.Post("/polls/{id}/replay", h.ReplayVotes)
+	})
 }
 
 // @Tags Polls
 // @Summary Create a new poll
-// @Description Create a new poll with a unique ID
+// @Description Create a new poll with a unique ID, owned by the authenticated caller
 // @Produce json
 // @Param poll body CreatePollRequest true "Poll data"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Header 429 {integer} Retry-After "Seconds to wait before retrying"
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /polls [post]
 func (h *Handler) CreatePoll(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, h.createPollRateRules(r)...) {
+		return
+	}
+
 	var req CreatePollRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		h.writeError(w, invalidField("body", "invalid request payload"))
 		return
 	}
-
-	poll := models.Poll{
-		Question: req.Question,
-		Options:  req.Options,
-		Votes:    make(map[string]int),
+	if err := validateCreatePoll(h.limits, req); err != nil {
+		h.writeError(w, err)
+		return
 	}
 
-	pollID, err := h.srv.CreatePoll(r.Context(), poll)
+	pollID, err := h.srv.CreatePoll(r.Context(), newPollFromRequest(req))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
 
@@ -72,28 +139,49 @@ func (h *Handler) CreatePoll(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func newPollFromRequest(req CreatePollRequest) models.Poll {
+	return models.Poll{
+		Question:          req.Question,
+		Options:           toOptions(req.Options),
+		Votes:             make(map[string]int),
+		AllowVoteChange:   req.AllowVoteChange,
+		Status:            models.PollStatus(req.Status),
+		OpensAt:           req.OpensAt,
+		ClosesAt:          req.ClosesAt,
+		BallotType:        models.BallotType(req.BallotType),
+		MinChoices:        req.MinChoices,
+		MaxChoices:        req.MaxChoices,
+		CreatedBy:         req.CreatedBy,
+		Tags:              req.Tags,
+		ResultsVisibility: models.ResultsVisibility(req.ResultsVisibility),
+	}
+}
+
 // @Tags Polls
 // @Summary Get a poll by ID
 // @Description Retrieve a poll by its unique ID
 // @Produce json
 // @Param id path string true "Poll ID"
 // @Success 200 {object} models.Poll
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Version of the poll, for If-Match"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /polls/{id} [get]
 func (h *Handler) GetPoll(w http.ResponseWriter, r *http.Request) {
-	pollID := chi.URLParam(r, "id")
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
 
 	poll, err := h.srv.GetPoll(r.Context(), pollID)
 	if err != nil {
-		if err.Error() == "poll not found" {
-			http.Error(w, "Poll not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		h.writeError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(poll.Version))
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(poll); err != nil {
 		http.Error(w, "Failed to encode poll response", http.StatusInternalServerError)
@@ -101,45 +189,131 @@ func (h *Handler) GetPoll(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Tags Polls
-// @Summary List all polls
-// @Description Retrieve a list of all polls
+// @Summary List polls
+// @Description Retrieve a page of polls matching the filters, newest first unless sorted otherwise. When more polls follow, the Link header holds the URL of the next page.
 // @Produce json
+// @Param status query string false "Only polls with this status" Enums(draft, open, closed, archived)
+// @Param creator query string false "Only polls created by this user"
+// @Param tag query []string false "Only polls carrying all of these tags" collectionFormat(multi)
+// @Param created_after query string false "Only polls created at or after this time (RFC 3339)"
+// @Param created_before query string false "Only polls created before this time (RFC 3339)"
+// @Param q query string false "Only polls with words in the question or options starting with each word of this text"
+// @Param sort query string false "Sort by creation time or total votes" Enums(created, votes) default(created)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(desc)
+// @Param limit query int false "Page size" default(20) maximum(100)
+// @Param cursor query string false "Cursor from the previous page's Link header"
 // @Success 200 {array} models.Poll
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} Link "URL of the next page, rel=\"next\""
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /polls [get]
 func (h *Handler) ListPolls(w http.ResponseWriter, r *http.Request) {
-	polls, err := h.srv.ListPolls(r.Context())
+	values := r.URL.Query()
+	query, err := pollQuery(values)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
+	query.Cursor = values.Get("cursor")
+	query.Limit = defaultPageLimit
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			h.writeError(w, invalidField("limit", fmt.Sprintf("limit must be between 1 and %d", maxPageLimit)))
+			return
+		}
+		query.Limit = limit
+	}
+
+	page, err := h.srv.ListPolls(r.Context(), query)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	if page.NextCursor != "" {
+		next := *r.URL
+		values.Set("cursor", page.NextCursor)
+		values.Set("limit", strconv.Itoa(query.Limit))
+		next.RawQuery = values.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
 
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(polls); err != nil {
+	if err := json.NewEncoder(w).Encode(page.Polls); err != nil {
 		http.Error(w, "Failed to encode polls response", http.StatusInternalServerError)
 		return
 	}
 }
 
+// pollQuery reads the filters and order of polls to list from the query
+// parameters.
+func pollQuery(values url.Values) (models.PollQuery, error) {
+	query := models.PollQuery{
+		Status:    models.PollStatus(values.Get("status")),
+		CreatedBy: values.Get("creator"),
+		Tags:      values["tag"],
+		Search:    values.Get("q"),
+		Sort:      models.PollSort(values.Get("sort")),
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return models.PollQuery{}, invalidField("order", "order must be asc or desc")
+	}
+
+	for name, bound := range map[string]**time.Time{
+		"created_after":  &query.CreatedAfter,
+		"created_before": &query.CreatedBefore,
+	} {
+		if value := values.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return models.PollQuery{}, invalidField(name, "must be an RFC 3339 time")
+			}
+			*bound = &parsed
+		}
+	}
+
+	return query, nil
+}
+
 // @Tags Polls
 // @Summary Delete a poll by ID
-// @Description Delete a poll by its unique ID
+// @Description Delete a poll by its unique ID. With If-Match, only the poll at that version is deleted.
 // @Produce json
 // @Param id path string true "Poll ID"
+// @Param If-Match header string false "ETag of the poll version to delete"
 // @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /polls/{id} [delete]
 func (h *Handler) DeletePoll(w http.ResponseWriter, r *http.Request) {
-	pollID := chi.URLParam(r, "id")
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
 
-	err := h.srv.DeletePoll(r.Context(), pollID)
+	version, err := ifMatchVersion(r)
 	if err != nil {
-		if err.Error() == "poll not found" {
-			http.Error(w, "Poll not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		h.writeError(w, err)
+		return
+	}
+
+	err = h.srv.DeletePoll(r.Context(), pollID, version)
+	if err != nil {
+		h.writeError(w, err)
 		return
 	}
 
@@ -156,41 +330,142 @@ func (h *Handler) DeletePoll(w http.ResponseWriter, r *http.Request) {
 
 // @Tags Polls
 // @Summary Update a poll by ID
-// @Description Update a poll's details by its unique ID
+// @Description Update a poll's details by its unique ID. With If-Match, only the poll at that version is updated.
 // @Produce json
 // @Param id path string true "Poll ID"
+// @Param If-Match header string false "ETag of the poll version to update"
 // @Param poll body UpdatePollRequest true "Poll data"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Version of the updated poll"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /polls/{id} [put]
 func (h *Handler) UpdatePoll(w http.ResponseWriter, r *http.Request) {
 	var req UpdatePollRequest
-	pollID := chi.URLParam(r, "id")
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		h.writeError(w, invalidField("body", "invalid request payload"))
+		return
+	}
+	if err := validateUpdatePoll(h.limits, req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.updatePoll(w, r, pollID, version, req)
+}
+
+// @Tags Polls
+// @Summary Partially update a poll by ID
+// @Description Apply a JSON Merge Patch (RFC 7396) to a poll's details: fields left out keep their value and null removes optional ones. A given options array replaces the options wholesale; while the poll is a draft, options listed with their id keep their votes, options without an id are added with no votes, and options left out are removed, which fails while anyone has voted for them. Once the poll is open, only option descriptions, images and order may change.
+// @Accept json
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Param If-Match header string false "ETag of the poll version to patch"
+// @Param patch body UpdatePollRequest true "Fields to change"
+// @Success 200 {object} map[string]string
+// @Header 200 {string} ETag "Version of the updated poll"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id} [patch]
+func (h *Handler) PatchPoll(w http.ResponseWriter, r *http.Request) {
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	var patch interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		h.writeError(w, invalidField("body", "invalid request payload"))
+		return
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		h.writeError(w, invalidField("body", "merge patch must be a JSON object"))
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	poll, err := h.srv.GetPoll(r.Context(), pollID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if version != 0 && version != poll.Version {
+		h.writeError(w, fmt.Errorf("%w: poll %s is at version %d", service.ErrVersionConflict, pollID, poll.Version))
+		return
+	}
+
+	req, err := applyMergePatch(toUpdatePollRequest(poll), patch)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if err := validateUpdatePoll(h.limits, req); err != nil {
+		h.writeError(w, err)
 		return
 	}
 
+	// The patch was applied to this version, so it must not land on another.
+	h.updatePoll(w, r, pollID, poll.Version, req)
+}
+
+// updatePoll stores the request's details if the poll is at the given
+// version, or at any version if that is 0.
+func (h *Handler) updatePoll(w http.ResponseWriter, r *http.Request, pollID string, version int64, req UpdatePollRequest) {
 	poll := models.Poll{
-		ID:       uuid.MustParse(pollID),
-		Question: req.Question,
-		Options:  req.Options,
-		Votes:    make(map[string]int),
+		Question:          req.Question,
+		Options:           toOptions(req.Options),
+		AllowVoteChange:   req.AllowVoteChange,
+		OpensAt:           req.OpensAt,
+		ClosesAt:          req.ClosesAt,
+		BallotType:        models.BallotType(req.BallotType),
+		MinChoices:        req.MinChoices,
+		MaxChoices:        req.MaxChoices,
+		Tags:              req.Tags,
+		ResultsVisibility: models.ResultsVisibility(req.ResultsVisibility),
+		Version:           version,
 	}
 
-	err := h.srv.UpdatePoll(r.Context(), pollID, poll)
+	updated, err := h.srv.UpdatePoll(r.Context(), pollID, poll)
 	if err != nil {
-		if err.Error() == "poll not found" {
-			http.Error(w, "Poll not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		h.writeError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(updated.Version))
 	w.WriteHeader(http.StatusOK)
 	if encodeErr := json.NewEncoder(w).Encode(map[string]string{
 		"status": "Poll updated successfully",
@@ -200,37 +475,142 @@ func (h *Handler) UpdatePoll(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// @Tags Polls
+// @Summary Open a poll for voting
+// @Description Move a draft poll to the open status
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Success 200 {object} models.Poll
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/open [post]
+func (h *Handler) OpenPoll(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, models.StatusOpen)
+}
+
+// @Tags Polls
+// @Summary Close a poll
+// @Description Stop accepting votes for an open poll
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Success 200 {object} models.Poll
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/close [post]
+func (h *Handler) ClosePoll(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, models.StatusClosed)
+}
+
+// @Tags Polls
+// @Summary Archive a poll
+// @Description Make a draft or closed poll read-only
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Success 200 {object} models.Poll
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/archive [post]
+func (h *Handler) ArchivePoll(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, models.StatusArchived)
+}
+
+func (h *Handler) changeStatus(w http.ResponseWriter, r *http.Request, status models.PollStatus) {
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	poll, err := h.srv.ChangeStatus(r.Context(), pollID, status)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(poll.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(poll); err != nil {
+		http.Error(w, "Failed to encode poll response", http.StatusInternalServerError)
+	}
+}
+
 // VoteHandler handles voting for a poll.
 // @Summary Vote for a poll
-// @Description Allows a user to vote for a poll option
+// @Description Allows a user to vote for a poll option. Voters are identified by their credentials, then their voter token, then user_id.
 // @Tags Poll
 // @Accept json
 // @Produce json
 // @Param id path string true "Poll ID"
 // @Param vote body VoteRequest true "Vote details"
+// @Param X-Voter-Token header string false "Voter token, unless sent as the voter_token cookie"
 // @Success 200 {object} map[string]string "Success message"
-// @Failure 400 {object} map[string]string "Invalid request payload"
-// @Failure 404 {object} map[string]string "Poll not found"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} ErrorResponse "Invalid request payload or ballot"
+// @Failure 401 {object} ErrorResponse "Invalid credentials or voter token, or anonymous voting is not allowed"
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Poll not found"
+// @Failure 409 {object} ErrorResponse "User has already voted or poll is not open"
+// @Failure 422 {object} ErrorResponse "Invalid fields"
+// @Failure 429 {object} ErrorResponse
+// @Header 429 {integer} Retry-After "Seconds to wait before retrying"
+// @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /polls/{id}/vote [post]
 func (h *Handler) VoteHandler(w http.ResponseWriter, r *http.Request) {
-	pollID := chi.URLParam(r, "id")
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
 
 	var req VoteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		h.writeError(w, invalidField("body", "invalid request payload"))
+		return
+	}
+	voterID, err := h.voterID(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	// The voter is identified by their credentials, then their voter token,
+	// and only then the user ID they sent.
+	_, authenticated := auth.FromContext(r.Context())
+	if err := validateVote(h.limits, req, authenticated || voterID != ""); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if !h.allow(w, r, h.voteRateRules(r, pollID, voterID)...) {
 		return
 	}
 
-	err := h.srv.Vote(r.Context(), pollID, req.Option)
+	err = h.srv.Vote(r.Context(), pollID, models.Vote{
+		UserID:    req.UserID,
+		VoterID:   voterID,
+		Option:    req.Option,
+		Options:   req.Options,
+		ClientIP:  h.clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
 	if err != nil {
-		if err.Error() == "poll not found" {
-			http.Error(w, "Poll not found", http.StatusNotFound)
-		} else if err.Error() == "option not found" {
-			http.Error(w, "Option not found", http.StatusBadRequest)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		h.writeError(w, err)
 		return
 	}
 
@@ -241,3 +621,104 @@ func (h *Handler) VoteHandler(w http.ResponseWriter, r *http.Request) {
 		h.log.Printf("error encoding response: %v", encodeErr)
 	}
 }
+
+// @Tags Polls
+// @Summary List vote events of a poll
+// @Description Retrieve every accepted vote of a poll from the vote event log, oldest first
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Success 200 {array} models.VoteEvent
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/events [get]
+func (h *Handler) ListVoteEvents(w http.ResponseWriter, r *http.Request) {
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	events, err := h.srv.ListVoteEvents(r.Context(), pollID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		http.Error(w, "Failed to encode vote events response", http.StatusInternalServerError)
+	}
+}
+
+// @Tags Polls
+// @Summary Rebuild vote counts from the vote event log
+// @Description Recompute a poll's vote counts from its vote event log and store them, unless dry_run is set
+// @Produce json
+// @Param id path string true "Poll ID"
+// @Param dry_run query bool false "Only compute the counts without storing them"
+// @Success 200 {object} models.VoteReplay
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/{id}/replay [post]
+func (h *Handler) ReplayVotes(w http.ResponseWriter, r *http.Request) {
+	pollID, err := pollIDParam(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	dryRun, err := boolParam(r, "dry_run")
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	replay, err := h.srv.ReplayVotes(r.Context(), pollID, !dryRun)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(replay); err != nil {
+		http.Error(w, "Failed to encode replay response", http.StatusInternalServerError)
+	}
+}
+
+// clientIP returns the IP the request comes from. That is the host part of
+// its remote address unless it is a trusted proxy; then X-Forwarded-For is
+// followed back from the end, past further trusted proxies, to the first
+// address added by someone else. Anything before that may be made up by the
+// client, so it is never used.
+func (h *Handler) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !h.trustedProxies.Contains(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !h.trustedProxies.Contains(hop) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"poll/auth"
+	"poll/configs"
+	"poll/ratelimit"
+	"poll/repo/memory"
+	"poll/service/basic"
+	results "poll/service/results/memory"
+	"testing"
+)
+
+// newTestServer serves the API over a memory repository. Validation limits
+// left unset in cfg get defaults.
+func newTestServer(t *testing.T, cfg configs.HTTPConfig, authenticators []auth.Authenticator, limiter ratelimit.Limiter) *httptest.Server {
+	if cfg.Validation == (configs.ValidationConfig{}) {
+		cfg.Validation = configs.ValidationConfig{
+			MaxQuestionLength: 100,
+			MinOptions:        2,
+			MaxOptions:        5,
+			MaxOptionLength:   50,
+			MaxUserIDLength:   20,
+			MaxImportPolls:    10,
+		}
+	}
+
+	publisher, err := results.New(16, results.PolicyCoalesce)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	repo := memory.New()
+	srv := basic.NewService(repo, repo, publisher)
+
+	router := chi.NewRouter()
+	NewHandler(log.New(io.Discard, "", 0), srv, cfg, authenticators, limiter).RegisterRoutes(router)
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// request sends body, if not nil, as JSON and decodes the JSON response
+// into out, if not nil.
+func request(t *testing.T, method, url string, header http.Header, body, out any) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("failed to decode response %s: %v", data, err)
+		}
+	}
+	return resp
+}
+
+// createPoll creates the poll and returns its URL.
+func createPoll(t *testing.T, ts *httptest.Server, header http.Header, poll map[string]any) string {
+	t.Helper()
+
+	var created struct {
+		PollID string `json:"pollID"`
+	}
+	resp := request(t, "POST", ts.URL+"/polls", header, poll, &created)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to create poll: %d", resp.StatusCode)
+	}
+	return ts.URL + "/polls/" + created.PollID
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"mime"
+	"net/http"
+	"poll/configs"
+	"poll/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportBytes caps the size of an import file.
+const maxImportBytes = 10 << 20
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// importColumns lists the columns a CSV import may have. Polls take an
+// option from every "option" column and a tag from every "tag" column;
+// empty ones are skipped.
+var importColumns = []string{
+	"question", "option", "allow_vote_change", "status", "opens_at", "closes_at", "ballot_type",
+	"min_choices", "max_choices", "created_by", "tag", "results_visibility",
+}
+
+// importRow is a poll read from an import file, or why it is invalid.
+type importRow struct {
+	req CreatePollRequest
+	err error
+}
+
+// @Tags Polls
+// @Summary Import polls
+// @Description Create many polls at once from a JSON array of polls as taken by POST /polls, or from CSV with a header naming the columns question, option, allow_vote_change, status, opens_at, closes_at, ballot_type, min_choices, max_choices, created_by, tag and results_visibility; option and tag may repeat. Every poll is validated and all valid ones are created in one transaction. With atomic set, none is created unless all are valid, which fails with 422; dry_run only validates them. The response reports the outcome for every poll, numbered from 1 in file order.
+// @Accept json,text/csv
+// @Produce json
+// @Param polls body []CreatePollRequest true "Polls to create"
+// @Param atomic query bool false "Only create the polls if all are valid"
+// @Param dry_run query bool false "Only validate the polls"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 422 {object} ImportResponse
+// @Failure 429 {object} ErrorResponse
+// @Header 429 {integer} Retry-After "Seconds to wait before retrying"
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /polls/import [post]
+func (h *Handler) ImportPolls(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, h.createPollRateRules(r)...) {
+		return
+	}
+
+	atomic, err := boolParam(r, "atomic")
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	dryRun, err := boolParam(r, "dry_run")
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	rows, err := readImport(w, r, h.limits)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	var polls []models.Poll
+	var valid []int
+	for i, row := range rows {
+		if row.err == nil {
+			polls = append(polls, newPollFromRequest(row.req))
+			valid = append(valid, i)
+		}
+	}
+	aborted := atomic && len(valid) < len(rows)
+
+	results, err := h.srv.ImportPolls(r.Context(), polls, atomic, dryRun || aborted)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	for i, result := range results {
+		rows[valid[i]].err = result.Err
+		if result.Err != nil {
+			aborted = atomic
+		}
+	}
+
+	resp := ImportResponse{Rows: make([]ImportRowResponse, len(rows))}
+	for i, row := range rows {
+		resp.Rows[i] = ImportRowResponse{Row: i + 1}
+		switch {
+		case row.err != nil:
+			_, errResp := h.errorResponse(row.err)
+			resp.Rows[i].Status = "failed"
+			resp.Rows[i].Error = &errResp
+			resp.Failed++
+		case dryRun:
+			resp.Rows[i].Status = "valid"
+		case aborted:
+			resp.Rows[i].Status = "skipped"
+		}
+	}
+	for i, result := range results {
+		if result.PollID != uuid.Nil {
+			resp.Rows[valid[i]].Status = "created"
+			resp.Rows[valid[i]].PollID = result.PollID.String()
+			resp.Created++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if aborted {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.log.Printf("error encoding import response: %v", err)
+	}
+}
+
+// readImport reads the polls of the request body, a JSON array or CSV as
+// its Content-Type says, and checks each against the limits.
+func readImport(w http.ResponseWriter, r *http.Request, limits configs.ValidationConfig) ([]importRow, error) {
+	mediaType := "application/json"
+	if value := r.Header.Get("Content-Type"); value != "" {
+		parsed, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedMediaType, value)
+		}
+		mediaType = parsed
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var rows []importRow
+	var err error
+	switch mediaType {
+	case "application/json":
+		rows, err = readJSONImport(body)
+	case "text/csv":
+		rows, err = readCSVImport(body)
+	default:
+		return nil, fmt.Errorf("%w: %s, send application/json or text/csv", errUnsupportedMediaType, mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, invalidField("body", "has no polls")
+	} else if len(rows) > limits.MaxImportPolls {
+		return nil, invalidField("body", fmt.Sprintf("must have at most %d polls", limits.MaxImportPolls))
+	}
+
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = validateCreatePoll(limits, rows[i].req)
+		}
+	}
+	return rows, nil
+}
+
+func readJSONImport(body io.Reader) ([]importRow, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&items); err != nil {
+		return nil, bodyError(err, "must be a JSON array of polls")
+	}
+
+	rows := make([]importRow, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &rows[i].req); err != nil {
+			rows[i].err = invalidField("body", "invalid poll")
+		}
+	}
+	return rows, nil
+}
+
+func readCSVImport(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, bodyError(err, fmt.Sprintf("invalid CSV: %v", err))
+	}
+
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if i == 0 {
+			// Spreadsheets often start UTF-8 files with a byte order mark.
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		if !slices.Contains(importColumns, column) {
+			return nil, invalidField("header", fmt.Sprintf("unknown column %q", column))
+		}
+		header[i] = column
+	}
+	if !slices.Contains(header, "question") {
+		return nil, invalidField("header", "must have a question column")
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		} else if err != nil {
+			return nil, bodyError(err, fmt.Sprintf("invalid CSV: %v", err))
+		}
+
+		req, err := csvPollRequest(header, record)
+		rows = append(rows, importRow{req: req, err: err})
+	}
+}
+
+// csvPollRequest reads a poll from a CSV record, reporting every cell that
+// does not parse.
+func csvPollRequest(header, record []string) (CreatePollRequest, error) {
+	var req CreatePollRequest
+	v := validator{}
+	for i, value := range record {
+		column := header[i]
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		switch column {
+		case "question":
+			req.Question = value
+		case "option":
+			req.Options = append(req.Options, OptionRequest{Label: value})
+		case "tag":
+			req.Tags = append(req.Tags, value)
+		case "status":
+			req.Status = value
+		case "ballot_type":
+			req.BallotType = value
+		case "created_by":
+			req.CreatedBy = value
+		case "results_visibility":
+			req.ResultsVisibility = value
+		case "allow_vote_change":
+			allow, err := strconv.ParseBool(value)
+			if err != nil {
+				v.add(column, "must be a boolean")
+			}
+			req.AllowVoteChange = allow
+		case "opens_at", "closes_at":
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				v.add(column, "must be an RFC 3339 time")
+				continue
+			}
+			if column == "opens_at" {
+				req.OpensAt = &at
+			} else {
+				req.ClosesAt = &at
+			}
+		case "min_choices", "max_choices":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				v.add(column, "must be an integer")
+			}
+			if column == "min_choices" {
+				req.MinChoices = n
+			} else {
+				req.MaxChoices = n
+			}
+		}
+	}
+	return req, v.err()
+}
+
+// bodyError reports an import body that could not be read, with the message
+// unless it was too large.
+func bodyError(err error, message string) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return invalidField("body", fmt.Sprintf("must be at most %d bytes", tooLarge.Limit))
+	}
+	return invalidField("body", message)
+}
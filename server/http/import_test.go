@@ -0,0 +1,273 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"poll/configs"
+	"poll/service"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var importLimits = configs.ValidationConfig{
+	MaxQuestionLength: 100,
+	MinOptions:        2,
+	MaxOptions:        5,
+	MaxOptionLength:   50,
+	MaxImportPolls:    3,
+}
+
+// fields returns the fields a validation error reports, or nil.
+func fields(err error) []string {
+	var field *service.ValidationError
+	if errors.As(err, &field) {
+		return []string{field.Field}
+	}
+	var errs service.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestReadImport(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantRows    int
+		wantRowErrs []bool
+		wantErr     error
+		wantFields  []string
+	}{
+		{
+			name:        "json by default",
+			body:        `[{"question":"Q?","options":["a","b"]}]`,
+			wantRows:    1,
+			wantRowErrs: []bool{false},
+		},
+		{
+			name:        "json with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `[{"question":"Q?","options":["a","b"]},{"question":"","options":["a"]}]`,
+			wantRows:    2,
+			wantRowErrs: []bool{false, true},
+		},
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			body:        "question,option,option\nQ?,a,b\nR?,a,\n",
+			wantRows:    2,
+			wantRowErrs: []bool{false, true},
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "application/xml",
+			body:        `<polls/>`,
+			wantErr:     errUnsupportedMediaType,
+		},
+		{
+			name:        "malformed content type",
+			contentType: "text/",
+			body:        `[]`,
+			wantErr:     errUnsupportedMediaType,
+		},
+		{
+			name:       "no polls",
+			body:       `[]`,
+			wantFields: []string{"body"},
+		},
+		{
+			name:       "too many polls",
+			body:       `[{},{},{},{}]`,
+			wantFields: []string{"body"},
+		},
+		{
+			name:       "not an array",
+			body:       `{"question":"Q?"}`,
+			wantFields: []string{"body"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/polls/import", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rows, err := readImport(httptest.NewRecorder(), r, importLimits)
+			if tt.wantErr != nil || tt.wantFields != nil {
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantFields != nil && !reflect.DeepEqual(fields(err), tt.wantFields) {
+					t.Fatalf("error fields = %v, want %v", fields(err), tt.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(rows) != tt.wantRows {
+				t.Fatalf("got %d rows, want %d", len(rows), tt.wantRows)
+			}
+			for i, row := range rows {
+				if (row.err != nil) != tt.wantRowErrs[i] {
+					t.Errorf("row %d error = %v, want error %v", i+1, row.err, tt.wantRowErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadImportTooLarge(t *testing.T) {
+	body := `[{"question":"` + strings.Repeat("x", maxImportBytes) + `"}]`
+	r := httptest.NewRequest("POST", "/polls/import", strings.NewReader(body))
+
+	_, err := readImport(httptest.NewRecorder(), r, importLimits)
+	if !reflect.DeepEqual(fields(err), []string{"body"}) || !strings.Contains(err.Error(), "bytes") {
+		t.Fatalf("error = %v, want a body size error", err)
+	}
+}
+
+func TestReadJSONImport(t *testing.T) {
+	body := `[
+		{"question":"Q?","options":["a",{"label":"b","description":"B"}],"tags":["x"],"ballot_type":"multi","max_choices":2},
+		{"question":3},
+		{"question":"R?","options":["a","b"],"allow_vote_change":true}
+	]`
+
+	rows, err := readJSONImport(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	want := CreatePollRequest{
+		Question:   "Q?",
+		Options:    []OptionRequest{{Label: "a"}, {Label: "b", Description: "B"}},
+		Tags:       []string{"x"},
+		BallotType: "multi",
+		MaxChoices: 2,
+	}
+	if rows[0].err != nil || !reflect.DeepEqual(rows[0].req, want) {
+		t.Errorf("row 1 = %+v, %v, want %+v", rows[0].req, rows[0].err, want)
+	}
+	if !reflect.DeepEqual(fields(rows[1].err), []string{"body"}) {
+		t.Errorf("row 2 error = %v, want an invalid poll", rows[1].err)
+	}
+	if rows[2].err != nil || !rows[2].req.AllowVoteChange {
+		t.Errorf("row 3 = %+v, %v, want a poll allowing vote changes", rows[2].req, rows[2].err)
+	}
+}
+
+func TestReadCSVImport(t *testing.T) {
+	opensAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		body       string
+		want       []CreatePollRequest
+		wantFields [][]string
+		wantErr    []string
+	}{
+		{
+			name: "repeated columns",
+			body: "question,option,option,option,tag,tag\nQ?,a,b,,x,y\n",
+			want: []CreatePollRequest{{
+				Question: "Q?",
+				Options:  []OptionRequest{{Label: "a"}, {Label: "b"}},
+				Tags:     []string{"x", "y"},
+			}},
+			wantFields: [][]string{nil},
+		},
+		{
+			name: "header with byte order mark, case and spaces",
+			body: "\ufeffQuestion , Option,option\n Q? , a ,b\n",
+			want: []CreatePollRequest{{
+				Question: "Q?",
+				Options:  []OptionRequest{{Label: "a"}, {Label: "b"}},
+			}},
+			wantFields: [][]string{nil},
+		},
+		{
+			name: "typed columns",
+			body: "question,option,option,allow_vote_change,status,opens_at,ballot_type,min_choices,max_choices,created_by,results_visibility\n" +
+				"Q?,a,b,true,open,2030-01-02T03:04:05Z,multi,1,2,alice,after_close\n",
+			want: []CreatePollRequest{{
+				Question:          "Q?",
+				Options:           []OptionRequest{{Label: "a"}, {Label: "b"}},
+				AllowVoteChange:   true,
+				Status:            "open",
+				OpensAt:           &opensAt,
+				BallotType:        "multi",
+				MinChoices:        1,
+				MaxChoices:        2,
+				CreatedBy:         "alice",
+				ResultsVisibility: "after_close",
+			}},
+			wantFields: [][]string{nil},
+		},
+		{
+			name:       "cells that do not parse",
+			body:       "question,allow_vote_change,closes_at,max_choices\nQ?,maybe,tomorrow,two\n",
+			wantFields: [][]string{{"allow_vote_change", "closes_at", "max_choices"}},
+		},
+		{
+			name:    "unknown column",
+			body:    "question,answer\nQ?,a\n",
+			wantErr: []string{"header"},
+		},
+		{
+			name:    "no question column",
+			body:    "option,option\na,b\n",
+			wantErr: []string{"header"},
+		},
+		{
+			name:    "ragged rows",
+			body:    "question,option\nQ?,a,b\n",
+			wantErr: []string{"body"},
+		},
+		{
+			name: "empty",
+			body: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readCSVImport(strings.NewReader(tt.body))
+			if tt.wantErr != nil {
+				if !reflect.DeepEqual(fields(err), tt.wantErr) {
+					t.Fatalf("error = %v, want an error on %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(rows) != len(tt.wantFields) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.wantFields))
+			}
+			for i, row := range rows {
+				if got := fields(row.err); !reflect.DeepEqual(got, tt.wantFields[i]) {
+					t.Errorf("row %d error fields = %v, want %v", i+1, got, tt.wantFields[i])
+				}
+				if tt.want != nil && !reflect.DeepEqual(row.req, tt.want[i]) {
+					t.Errorf("row %d = %+v, want %+v", i+1, row.req, tt.want[i])
+				}
+			}
+		})
+	}
+}
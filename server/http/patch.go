@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"poll/models"
+)
+
+// toUpdatePollRequest renders the poll's editable details the way an update
+// request carries them, so a merge patch can be applied on top.
+func toUpdatePollRequest(poll *models.Poll) UpdatePollRequest {
+	options := make([]OptionRequest, len(poll.Options))
+	for i, option := range poll.Options {
+		options[i] = OptionRequest{
+			ID:          option.ID,
+			Label:       option.Label,
+			Description: option.Description,
+			ImageURL:    option.ImageURL,
+			Order:       option.Order,
+		}
+	}
+
+	return UpdatePollRequest{
+		Question:          poll.Question,
+		Options:           options,
+		AllowVoteChange:   poll.AllowVoteChange,
+		OpensAt:           poll.OpensAt,
+		ClosesAt:          poll.ClosesAt,
+		BallotType:        string(poll.BallotType),
+		MinChoices:        poll.MinChoices,
+		MaxChoices:        poll.MaxChoices,
+		Tags:              poll.Tags,
+		ResultsVisibility: string(poll.ResultsVisibility),
+	}
+}
+
+// applyMergePatch applies a JSON Merge Patch (RFC 7396) to the request.
+// Fields the request does not know are rejected rather than ignored, so a
+// patch of, say, the status fails loudly.
+func applyMergePatch(req UpdatePollRequest, patch interface{}) (UpdatePollRequest, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return req, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return req, err
+	}
+
+	data, err = json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return req, err
+	}
+
+	var patched UpdatePollRequest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return req, invalidField("body", err.Error())
+	}
+	return patched, nil
+}
+
+// mergePatch merges patch into target: objects are merged key by key, null
+// removes a key and any other value replaces the target's.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{}, len(patchObject))
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
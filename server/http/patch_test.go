@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"poll/configs"
+	"poll/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+// TestMergePatch runs the examples of RFC 7396, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		got := mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+		if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("merging %s into %s = %v, want %v", tt.patch, tt.target, got, want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	opensAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	poll := &models.Poll{
+		Question: "Fruit?",
+		Options: []models.Option{
+			{ID: "a", Label: "Apple", Description: "Red", Order: 0},
+			{ID: "p", Label: "Pear", Order: 1},
+		},
+		Votes:             map[string]int{"a": 3},
+		AllowVoteChange:   true,
+		Status:            models.StatusOpen,
+		OpensAt:           &opensAt,
+		BallotType:        models.BallotMulti,
+		MaxChoices:        2,
+		Tags:              []string{"food"},
+		ResultsVisibility: models.ResultsAfterVote,
+		Version:           4,
+	}
+	base := toUpdatePollRequest(poll)
+
+	tests := []struct {
+		name      string
+		patch     string
+		want      func(req *UpdatePollRequest)
+		wantField string
+	}{
+		{
+			name:  "empty patch",
+			patch: `{}`,
+			want:  func(req *UpdatePollRequest) {},
+		},
+		{
+			name:  "replace a field",
+			patch: `{"question":"Fruits?","allow_vote_change":false}`,
+			want: func(req *UpdatePollRequest) {
+				req.Question = "Fruits?"
+				req.AllowVoteChange = false
+			},
+		},
+		{
+			name:  "null removes optional fields",
+			patch: `{"opens_at":null,"tags":null,"max_choices":null}`,
+			want: func(req *UpdatePollRequest) {
+				req.OpensAt = nil
+				req.Tags = nil
+				req.MaxChoices = 0
+			},
+		},
+		{
+			name:  "options are replaced wholesale",
+			patch: `{"options":[{"id":"p","label":"Pear"},"Plum"]}`,
+			want: func(req *UpdatePollRequest) {
+				req.Options = []OptionRequest{{ID: "p", Label: "Pear"}, {Label: "Plum"}}
+			},
+		},
+		{
+			name:  "nested option fields are not merged",
+			patch: `{"options":[{"id":"a"}]}`,
+			want: func(req *UpdatePollRequest) {
+				req.Options = []OptionRequest{{ID: "a"}}
+			},
+		},
+		{name: "status", patch: `{"status":"closed"}`, wantField: "body"},
+		{name: "votes", patch: `{"votes":{"a":100}}`, wantField: "body"},
+		{name: "version", patch: `{"version":1}`, wantField: "body"},
+		{name: "owner", patch: `{"created_by":"mallory"}`, wantField: "body"},
+		{name: "wrong type", patch: `{"question":{"text":"Fruit?"}}`, wantField: "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyMergePatch(base, decodeJSON(t, tt.patch))
+			if tt.wantField != "" {
+				if f := fields(err); len(f) != 1 || f[0] != tt.wantField {
+					t.Fatalf("error = %v, want an invalid %s", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := toUpdatePollRequest(poll)
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestPatchPollKeepsVotes(t *testing.T) {
+	ts := newTestServer(t, configs.HTTPConfig{}, nil, nil)
+	url := createPoll(t, ts, nil, map[string]any{
+		"question": "Fruit?",
+		"options":  []string{"apple", "pear", "plum"},
+	})
+
+	var poll models.Poll
+	request(t, "GET", url, nil, nil, &poll)
+	apple, pear, plum := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+
+	// Options may change freely while the poll is a draft.
+	resp := request(t, "PATCH", url, nil, map[string]any{"options": []any{
+		map[string]any{"id": apple, "label": "Green apple"},
+		map[string]any{"id": pear, "label": "pear"},
+		"kiwi",
+	}}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("patching the options of a draft = %d, want 200", resp.StatusCode)
+	}
+
+	poll = models.Poll{}
+	request(t, "GET", url, nil, nil, &poll)
+	if len(poll.Options) != 3 || poll.Options[0].Label != "Green apple" || poll.Options[2].Label != "kiwi" {
+		t.Fatalf("got options %+v, want the patched options", poll.Options)
+	}
+	for _, option := range poll.Options {
+		if option.ID == plum {
+			t.Fatalf("option %s was not removed", plum)
+		}
+	}
+	kiwi := poll.Options[2].ID
+
+	if resp := request(t, "POST", url+"/open", nil, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("opening the poll = %d, want 200", resp.StatusCode)
+	}
+	for _, user := range []string{"u1", "u2"} {
+		if resp := request(t, "POST", url+"/vote", nil, map[string]any{"option": apple, "user_id": user}, nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("vote failed: %d", resp.StatusCode)
+		}
+	}
+
+	resp = request(t, "PATCH", url, nil, map[string]any{"question": "Fruits?"}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("patching the question = %d, want 200", resp.StatusCode)
+	}
+
+	resp = request(t, "PATCH", url, nil, map[string]any{"options": []any{
+		map[string]any{"id": kiwi, "label": "kiwi", "order": 0},
+		map[string]any{"id": apple, "label": "Green apple", "description": "Sour", "order": 1},
+		map[string]any{"id": pear, "label": "pear", "order": 2},
+	}}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("describing and reordering the options = %d, want 200", resp.StatusCode)
+	}
+
+	poll = models.Poll{}
+	request(t, "GET", url, nil, nil, &poll)
+	if poll.Question != "Fruits?" || len(poll.Options) != 3 || poll.Options[1].Description != "Sour" || poll.Options[0].ID != kiwi {
+		t.Fatalf("got poll %+v, want the patched question and options", poll)
+	}
+	if poll.Votes[apple] != 2 {
+		t.Fatalf("got votes %v, want the 2 votes for %s kept", poll.Votes, apple)
+	}
+
+	var errResp ErrorResponse
+	for name, options := range map[string][]any{
+		"relabelling": {map[string]any{"id": apple, "label": "Red apple"}, map[string]any{"id": pear, "label": "pear"}, map[string]any{"id": kiwi, "label": "kiwi"}},
+		"adding":      {map[string]any{"id": apple, "label": "Green apple"}, map[string]any{"id": pear, "label": "pear"}, map[string]any{"id": kiwi, "label": "kiwi"}, "fig"},
+		"removing":    {map[string]any{"id": apple, "label": "Green apple"}, map[string]any{"id": pear, "label": "pear"}},
+	} {
+		resp = request(t, "PATCH", url, nil, map[string]any{"options": options}, &errResp)
+		if resp.StatusCode != http.StatusConflict || errResp.Code != "poll_locked" {
+			t.Fatalf("%s options of an open poll = %d %s, want 409 poll_locked", name, resp.StatusCode, errResp.Code)
+		}
+	}
+
+	resp = request(t, "PATCH", url, nil, []string{"question"}, &errResp)
+	if resp.StatusCode != http.StatusBadRequest || errResp.Field != "body" {
+		t.Fatalf("patching with an array = %d %+v, want 400 on body", resp.StatusCode, errResp)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"poll/auth"
+	"poll/models"
+	"poll/ratelimit"
+	"strconv"
+)
+
+// allow counts the request against the rules and responds with 429 and a
+// Retry-After header if it exceeds any of them, in which case it is counted
+// against none. It returns whether the request may proceed. Requests are let
+// through when the limiter fails, so an unavailable Redis does not take
+// voting down with it.
+func (h *Handler) allow(w http.ResponseWriter, r *http.Request, rules ...ratelimit.Rule) bool {
+	if h.limiter == nil {
+		return true
+	}
+
+	retryAfter, err := h.limiter.Allow(r.Context(), rules...)
+	if err != nil {
+		h.log.Printf("failed to check rate limits: %v", err)
+		return true
+	}
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		h.writeError(w, fmt.Errorf("%w: retry in %ds", ratelimit.ErrLimited, seconds))
+		return false
+	}
+	return true
+}
+
+// voteRateRules limits votes in the poll per client IP, per voter and in
+// all. Only verified voters are limited per voter, as anyone can send a
+// user ID of someone else to use up their votes; the limit per client IP
+// is what holds back the others.
+func (h *Handler) voteRateRules(r *http.Request, pollID, voterID string) []ratelimit.Rule {
+	limits := h.rateLimits.Vote
+	rules := []ratelimit.Rule{
+		{Key: "vote:ip:" + pollID + ":" + h.clientIP(r), Rate: limits.PerIP},
+		{Key: "vote:poll:" + pollID, Rate: limits.PerPoll},
+	}
+
+	vote := models.Vote{VoterID: voterID}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		vote.Subject = principal.Subject
+	}
+	if voter := vote.Voter(); voter != "" {
+		rules = append(rules, ratelimit.Rule{Key: "vote:user:" + pollID + ":" + voter, Rate: limits.PerUser})
+	}
+	return rules
+}
+
+// createPollRateRules limits poll creation per client IP and per
+// authenticated caller.
+func (h *Handler) createPollRateRules(r *http.Request) []ratelimit.Rule {
+	limits := h.rateLimits.CreatePoll
+	rules := []ratelimit.Rule{{Key: "create_poll:ip:" + h.clientIP(r), Rate: limits.PerIP}}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		rules = append(rules, ratelimit.Rule{Key: "create_poll:user:" + principal.Subject, Rate: limits.PerUser})
+	}
+	return rules
+}
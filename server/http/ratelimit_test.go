@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http/httptest"
+	"poll/auth"
+	"poll/configs"
+	"testing"
+	"time"
+)
+
+func TestVoteRateRules(t *testing.T) {
+	perUser := configs.Rate{Limit: 1, Period: time.Minute}
+	h := &Handler{rateLimits: configs.RateLimitConfig{Vote: configs.VoteRateLimits{PerUser: perUser}}}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		voterID   string
+		want      string
+	}{
+		{name: "anonymous"},
+		{name: "voter token", voterID: "v1", want: "vote:user:p1:vt:v1"},
+		{name: "authenticated", principal: &auth.Principal{Subject: "alice"}, want: "vote:user:p1:sub:alice"},
+		{name: "authenticated with a voter token", principal: &auth.Principal{Subject: "alice"}, voterID: "v1", want: "vote:user:p1:sub:alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/polls/p1/vote", nil)
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			}
+
+			var got string
+			for _, rule := range h.voteRateRules(r, "p1", tt.voterID) {
+				if rule.Rate == perUser {
+					got = rule.Key
+				}
+			}
+			if got != tt.want {
+				t.Errorf("per voter key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	var proxies configs.Networks
+	if err := proxies.UnmarshalText([]byte("10.0.0.0/8, 192.0.2.1")); err != nil {
+		t.Fatalf("failed to parse proxies: %v", err)
+	}
+	h := &Handler{trustedProxies: proxies}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "untrusted proxy", remoteAddr: "203.0.113.5:1234", forwardedFor: []string{"198.51.100.7"}, want: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.7, 192.0.2.1", "10.9.9.9"}, want: "198.51.100.7"},
+		{name: "spoofed hops are ignored", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"1.1.1.1, 198.51.100.7"}, want: "198.51.100.7"},
+		{name: "invalid hop", remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"198.51.100.7, unknown"}, want: "10.1.2.3"},
+		{name: "trusted proxy without header", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/polls/p1/vote", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := h.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
+	"poll/auth"
+	"poll/configs"
+	"poll/ratelimit"
 	"poll/service"
 )
 
@@ -20,18 +23,18 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func NewServer(log *log.Logger, srv service.PollService) *Server {
+func NewServer(log *log.Logger, srv service.PollService, cfg configs.HTTPConfig, authenticators []auth.Authenticator, limiter ratelimit.Limiter) *Server {
 	r := chi.NewRouter()
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://your-frontend-domain.com"}, // Замените на ваши домены
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"X-Subject-Token", "Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", auth.APIKeyHeader, auth.VoterTokenHeader},
+		ExposedHeaders:   []string{"X-Subject-Token", "Link", "ETag", "Retry-After", "Content-Disposition"},
 		AllowCredentials: true,
 	}))
 
-	h := NewHandler(log, srv)
+	h := NewHandler(log, srv, cfg, authenticators, limiter)
 	h.RegisterRoutes(r)
 
 	return &Server{
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"net/http"
+	"poll/configs"
+	"poll/service"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validator checks request bodies against the configured limits before they
+// reach the service. It reports every invalid field, not just the first.
+type validator struct {
+	limits configs.ValidationConfig
+	errs   service.ValidationErrors
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, service.ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *validator) text(field, value string, maxLength int) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	} else if utf8.RuneCountInString(value) > maxLength {
+		v.add(field, "must be at most %d characters", maxLength)
+	}
+}
+
+func (v *validator) poll(question string, options []OptionRequest) {
+	v.text("question", question, v.limits.MaxQuestionLength)
+
+	if len(options) < v.limits.MinOptions {
+		v.add("options", "must have at least %d options", v.limits.MinOptions)
+	} else if len(options) > v.limits.MaxOptions {
+		v.add("options", "must have at most %d options", v.limits.MaxOptions)
+	}
+
+	// Labels are compared the way voters read them, ignoring case and
+	// surrounding spaces.
+	seen := make(map[string]int, len(options))
+	for i, option := range options {
+		field := fmt.Sprintf("options[%d].label", i)
+		v.text(field, option.Label, v.limits.MaxOptionLength)
+
+		label := strings.ToLower(strings.TrimSpace(option.Label))
+		if first, ok := seen[label]; ok && label != "" {
+			v.add(field, "duplicates options[%d]", first)
+		} else {
+			seen[label] = i
+		}
+	}
+}
+
+func validateCreatePoll(limits configs.ValidationConfig, req CreatePollRequest) error {
+	v := validator{limits: limits}
+	v.poll(req.Question, req.Options)
+	return v.err()
+}
+
+func validateUpdatePoll(limits configs.ValidationConfig, req UpdatePollRequest) error {
+	v := validator{limits: limits}
+	v.poll(req.Question, req.Options)
+	return v.err()
+}
+
+// validateVote checks the vote. The user ID is only checked if it is what
+// identifies the voter, rather than their credentials or voter token.
+func validateVote(limits configs.ValidationConfig, req VoteRequest, identified bool) error {
+	v := validator{limits: limits}
+	if !identified {
+		v.text("user_id", req.UserID, limits.MaxUserIDLength)
+	}
+	if req.Option == "" && len(req.Options) == 0 {
+		v.add("option", "option or options is required")
+	} else if req.Option != "" && len(req.Options) > 0 {
+		v.add("option", "only one of option and options may be set")
+	}
+	return v.err()
+}
+
+// boolParam returns the boolean query parameter, which is false if absent.
+func boolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidField(name, "must be a boolean")
+	}
+	return parsed, nil
+}
+
+// pollIDParam returns the poll ID of the request path in its canonical form.
+func pollIDParam(r *http.Request) (string, error) {
+	pollID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return "", invalidField("id", "must be a UUID")
+	}
+	return pollID.String(), nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"poll/configs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestValidation(t *testing.T) {
+	ts := newTestServer(t, configs.HTTPConfig{}, nil, nil)
+	pollPath := strings.TrimPrefix(createPoll(t, ts, nil, map[string]any{
+		"question": "Fruit?",
+		"options":  []string{"apple", "pear"},
+		"status":   "open",
+	}), ts.URL)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantErrors []FieldError
+	}{
+		{
+			name:   "missing question and too few options",
+			method: "POST",
+			path:   "/polls",
+			body:   map[string]any{"question": "  ", "options": []string{"apple"}},
+			wantErrors: []FieldError{
+				{Field: "question", Message: "is required"},
+				{Field: "options", Message: "must have at least 2 options"},
+			},
+		},
+		{
+			name:   "too many options",
+			method: "POST",
+			path:   "/polls",
+			body:   map[string]any{"question": "Fruit?", "options": []string{"a", "b", "c", "d", "e", "f"}},
+			wantErrors: []FieldError{
+				{Field: "options", Message: "must have at most 5 options"},
+			},
+		},
+		{
+			name:   "long question and labels",
+			method: "POST",
+			path:   "/polls",
+			body:   map[string]any{"question": strings.Repeat("q", 101), "options": []string{"apple", strings.Repeat("é", 51)}},
+			wantErrors: []FieldError{
+				{Field: "question", Message: "must be at most 100 characters"},
+				{Field: "options[1].label", Message: "must be at most 50 characters"},
+			},
+		},
+		{
+			name:   "empty and duplicate labels",
+			method: "PUT",
+			path:   pollPath,
+			body:   map[string]any{"question": "Fruit?", "options": []string{"Apple", "", " apple "}},
+			wantErrors: []FieldError{
+				{Field: "options[1].label", Message: "is required"},
+				{Field: "options[2].label", Message: "duplicates options[0]"},
+			},
+		},
+		{
+			name:   "vote without user ID or option",
+			method: "POST",
+			path:   pollPath + "/vote",
+			body:   map[string]any{},
+			wantErrors: []FieldError{
+				{Field: "user_id", Message: "is required"},
+				{Field: "option", Message: "option or options is required"},
+			},
+		},
+		{
+			name:   "vote with option and options",
+			method: "POST",
+			path:   pollPath + "/vote",
+			body:   map[string]any{"user_id": strings.Repeat("u", 21), "option": "a", "options": []string{"a"}},
+			wantErrors: []FieldError{
+				{Field: "user_id", Message: "must be at most 20 characters"},
+				{Field: "option", Message: "only one of option and options may be set"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errResp ErrorResponse
+			resp := request(t, tt.method, ts.URL+tt.path, nil, tt.body, &errResp)
+			if resp.StatusCode != http.StatusUnprocessableEntity || errResp.Code != "validation_failed" {
+				t.Fatalf("got %d %s, want 422 validation_failed", resp.StatusCode, errResp.Code)
+			}
+			if !reflect.DeepEqual(errResp.Errors, tt.wantErrors) {
+				t.Errorf("got errors %+v, want %+v", errResp.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"poll/auth"
+	"poll/ratelimit"
+)
+
+// @Tags Voters
+// @Summary Issue a voter token
+// @Description Issue a signed voter token, which identifies a voter without an account. It is also set as the voter_token cookie; other clients send it back in the X-Voter-Token header. A request that already carries a valid token gets that token back.
+// @Produce json
+// @Success 200 {object} VoterTokenResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Header 429 {integer} Retry-After "Seconds to wait before retrying"
+// @Failure 500 {object} ErrorResponse
+// @Router /voter-tokens [post]
+func (h *Handler) IssueVoterToken(w http.ResponseWriter, r *http.Request) {
+	token, err := h.voterToken(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	if token == nil {
+		if !h.allow(w, r, ratelimit.Rule{Key: "voter_token:ip:" + h.clientIP(r), Rate: h.rateLimits.VoterToken.PerIP}) {
+			return
+		}
+		if token, err = h.voterTokens.Issue(); err != nil {
+			h.writeError(w, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.VoterTokenCookie,
+		Value:    token.Token,
+		Path:     "/",
+		Expires:  token.ExpiresAt,
+		HttpOnly: true,
+		Secure:   h.voterTokenCfg.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(VoterTokenResponse{
+		Token:     token.Token,
+		VoterID:   token.VoterID,
+		ExpiresAt: token.ExpiresAt,
+	}); err != nil {
+		h.log.Printf("error encoding response: %v", err)
+	}
+}
+
+// voterToken returns the verified voter token the request carries in the
+// X-Voter-Token header or, failing that, the voter_token cookie. It returns
+// nil if there is none or voter tokens are disabled.
+func (h *Handler) voterToken(r *http.Request) (*auth.VoterToken, error) {
+	if h.voterTokens == nil {
+		return nil, nil
+	}
+
+	token := r.Header.Get(auth.VoterTokenHeader)
+	if token == "" {
+		cookie, err := r.Cookie(auth.VoterTokenCookie)
+		if errors.Is(err, http.ErrNoCookie) {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", auth.ErrInvalidVoterToken, err)
+		}
+		token = cookie.Value
+	}
+
+	return h.voterTokens.Verify(token)
+}
+
+// identifyVoter puts the voter ID of the request's voter token into its
+// context. Invalid tokens are ignored here, so a stale cookie does not keep
+// anyone from reading polls; voting with one fails instead.
+func (h *Handler) identifyVoter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, err := h.voterToken(r); err == nil && token != nil {
+			r = r.WithContext(auth.WithVoterID(r.Context(), token.VoterID))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// voterID returns the voter ID of the request's voter token, if any. When
+// voter tokens are required, voters that did not authenticate must have one.
+func (h *Handler) voterID(r *http.Request) (string, error) {
+	token, err := h.voterToken(r)
+	if err != nil {
+		return "", err
+	}
+	if token != nil {
+		return token.VoterID, nil
+	}
+
+	if _, ok := auth.FromContext(r.Context()); !ok && h.voterTokens != nil && h.voterTokenCfg.Required {
+		return "", fmt.Errorf("%w: a voter token is required", auth.ErrInvalidVoterToken)
+	}
+	return "", nil
+}
@@ -1,85 +1,359 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
 	"poll/models"
+	"poll/service"
+	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+
+	pollPathPrefix = "/ws/polls/"
+
+	snapshotTimeout = 5 * time.Second
+
+	// sendQueueSize is how many messages may wait for a client's writer.
+	// Clients falling further behind are disconnected.
+	sendQueueSize = 64
+	// writeTimeout bounds how long writing a single message may take.
+	writeTimeout = 10 * time.Second
+)
+
+// subscriptionMessage is what clients send on the socket to choose which
+// polls they receive results for.
+type subscriptionMessage struct {
+	Action string `json:"action"`
+	PollID string `json:"poll_id"`
+}
+
+type errorMessage struct {
+	Error string `json:"error"`
+}
+
+// client is a connection whose messages are queued in send and written by
+// its own writer goroutine, so a slow client only holds up itself.
+type client struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	// polls is guarded by the server's mu.
+	polls map[string]struct{}
+
+	mu sync.Mutex
+	// loading holds, per poll whose snapshot is being loaded, the latest
+	// results published meanwhile, which are sent after the snapshot.
+	loading map[string][]byte
+}
+
+func newClient(conn *websocket.Conn) *client {
+	return &client{
+		conn:    conn,
+		send:    make(chan []byte, sendQueueSize),
+		done:    make(chan struct{}),
+		polls:   make(map[string]struct{}),
+		loading: make(map[string][]byte),
+	}
+}
+
+// close disconnects the client, which stops its reader and writer.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// writeMessages writes queued messages to the connection until the client
+// is disconnected.
+func (s *Server) writeMessages(c *client) {
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				s.logger.Printf("error writing message: %v", err)
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// queue hands the message to the client's writer. A client whose queue is
+// full has fallen behind and is disconnected rather than holding up anyone
+// else.
+func (s *Server) queue(c *client, msg []byte) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		s.logger.Printf("disconnecting client %s, which fell behind", c.conn.RemoteAddr())
+		c.close()
+	}
+}
+
 type Server struct {
 	upgrader       websocket.Upgrader
-	connections    map[*websocket.Conn]bool
+	mu             sync.RWMutex
+	subscriptions  map[string]map[*client]struct{}
 	resultsChannel <-chan models.PollResults
+	polls          service.PollService
 	logger         *log.Logger
 }
 
-func New(logger *log.Logger, resultsChannel <-chan models.PollResults) *Server {
+func New(logger *log.Logger, resultsChannel <-chan models.PollResults, polls service.PollService) *Server {
 	return &Server{
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
-		connections:    make(map[*websocket.Conn]bool),
+		subscriptions:  make(map[string]map[*client]struct{}),
 		resultsChannel: resultsChannel,
+		polls:          polls,
 		logger:         logger,
 	}
 }
 
+// handleConnections serves /ws. The client receives nothing until it sends
+// a subscribe message for one or more polls.
 func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r)
+}
+
+// handlePollConnections serves /ws/polls/{id}, subscribing the client to
+// that poll right away. Further subscribe/unsubscribe messages still work.
+func (s *Server) handlePollConnections(w http.ResponseWriter, r *http.Request) {
+	pollID := strings.TrimPrefix(r.URL.Path, pollPathPrefix)
+	if pollID == "" || strings.Contains(pollID, "/") {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	s.serve(w, r, pollID)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, pollIDs ...string) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.logger.Printf("failed to upgrade connection: %v", err)
 		return
 	}
 
-	s.connections[conn] = true
+	c := newClient(conn)
+	go s.writeMessages(c)
+
+	for _, pollID := range pollIDs {
+		s.subscribe(c, pollID)
+	}
 
 	go func() {
 		defer func() {
-			if closeErr := conn.Close(); closeErr != nil {
-				s.logger.Printf("error closing connection: %v", closeErr)
-			}
+			s.unsubscribeAll(c)
+			c.close()
 		}()
 
 		for {
-			_, _, err := conn.ReadMessage()
+			_, data, err := conn.ReadMessage()
 			if err != nil {
 				s.logger.Printf("error reading message: %v", err)
 				break
 			}
-		}
 
-		delete(s.connections, conn)
+			s.handleMessage(c, data)
+		}
 	}()
 }
 
+func (s *Server) handleMessage(c *client, data []byte) {
+	var msg subscriptionMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.writeError(c, "invalid message")
+		return
+	}
+
+	if msg.PollID == "" {
+		s.writeError(c, "poll_id is required")
+		return
+	}
+
+	switch msg.Action {
+	case actionSubscribe:
+		s.subscribe(c, msg.PollID)
+	case actionUnsubscribe:
+		s.unsubscribe(c, msg.PollID)
+	default:
+		s.writeError(c, fmt.Sprintf("unknown action: %s", msg.Action))
+	}
+}
+
+func (s *Server) writeError(c *client, text string) {
+	msg, err := json.Marshal(errorMessage{Error: text})
+	if err != nil {
+		s.logger.Printf("error marshaling error message: %v", err)
+		return
+	}
+
+	s.queue(c, msg)
+}
+
+// subscribe registers the client for live results of the poll and queues
+// the current results as the first frame. Results published while those are
+// loaded are held back and queued after them, so the client never sees an
+// older snapshot after a newer update.
+func (s *Server) subscribe(c *client, pollID string) {
+	c.mu.Lock()
+	c.loading[pollID] = nil
+	c.mu.Unlock()
+	s.addSubscriber(c, pollID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	results, err := s.polls.GetResults(ctx, pollID)
+	if err != nil {
+		s.logger.Printf("error loading snapshot for poll %s: %v", pollID, err)
+		s.unsubscribe(c, pollID)
+		c.mu.Lock()
+		delete(c.loading, pollID)
+		c.mu.Unlock()
+
+		if errors.Is(err, service.ErrPollNotFound) {
+			s.writeError(c, fmt.Sprintf("poll %s not found", pollID))
+		} else {
+			s.writeError(c, fmt.Sprintf("failed to load poll %s", pollID))
+		}
+		return
+	}
+
+	msg, err := json.Marshal(results)
+	if err != nil {
+		s.logger.Printf("error marshaling poll results: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	held := c.loading[pollID]
+	delete(c.loading, pollID)
+	if err == nil {
+		s.queue(c, msg)
+	}
+	if held != nil {
+		s.queue(c, held)
+	}
+}
+
+// deliver queues published results of the poll for the client, or holds
+// them back while the client's snapshot of the poll is loaded.
+func (s *Server) deliver(c *client, pollID string, msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, loading := c.loading[pollID]; loading {
+		c.loading[pollID] = msg
+		return
+	}
+	s.queue(c, msg)
+}
+
+func (s *Server) addSubscriber(c *client, pollID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribers, ok := s.subscriptions[pollID]
+	if !ok {
+		subscribers = make(map[*client]struct{})
+		s.subscriptions[pollID] = subscribers
+	}
+	subscribers[c] = struct{}{}
+	c.polls[pollID] = struct{}{}
+}
+
+func (s *Server) unsubscribe(c *client, pollID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(c, pollID)
+}
+
+func (s *Server) unsubscribeAll(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for pollID := range c.polls {
+		s.removeLocked(c, pollID)
+	}
+}
+
+func (s *Server) removeLocked(c *client, pollID string) {
+	delete(c.polls, pollID)
+
+	subscribers := s.subscriptions[pollID]
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		delete(s.subscriptions, pollID)
+	}
+}
+
+func (s *Server) subscribers(pollID string) []*client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := make([]*client, 0, len(s.subscriptions[pollID]))
+	for c := range s.subscriptions[pollID] {
+		clients = append(clients, c)
+	}
+
+	return clients
+}
+
+// handleResults fans published results out to the send queues of their
+// subscribers. These are anonymous, so vote counts are only sent while the
+// poll's results visibility shows them to everyone.
 func (s *Server) handleResults() {
 	for result := range s.resultsChannel {
+		if !result.Visibility.Reveals(result.Status, false) {
+			result.Redact()
+		}
+
 		msg, err := json.Marshal(result)
 		if err != nil {
 			s.logger.Printf("error marshaling poll results: %v", err)
 			continue
 		}
 
-		for conn := range s.connections {
-			err := conn.WriteMessage(websocket.TextMessage, msg)
-			if err != nil {
-				s.logger.Printf("error writing message: %v", err)
-				conn.Close()
-				delete(s.connections, conn)
-			}
+		for _, c := range s.subscribers(result.PollID) {
+			s.deliver(c, result.PollID, msg)
 		}
 	}
 }
 
+// handler routes the server's endpoints.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handleConnections)
+	mux.HandleFunc(pollPathPrefix, s.handlePollConnections)
+	return mux
+}
+
 func (s *Server) Start(addr string) error {
-	http.HandleFunc("/ws", s.handleConnections)
 	go s.handleResults()
 
 	s.logger.Printf("websocket server listening on %s", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(addr, s.handler()); err != nil {
 		return fmt.Errorf("websocket server failed: %w", err)
 	}
 
@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http/httptest"
+	"poll/models"
+	"poll/repo/memory"
+	"poll/service/basic"
+	results "poll/service/results/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer serves a poll service with a single open poll and returns
+// the server, the channel its results come from, the poll's ID and the
+// server's base URL.
+func newTestServer(t *testing.T) (*Server, chan models.PollResults, string, string) {
+	t.Helper()
+
+	publisher, err := results.New(16, results.PolicyCoalesce)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	repo := memory.New()
+	polls := basic.NewService(repo, repo, publisher)
+
+	id, err := polls.CreatePoll(context.Background(), models.Poll{
+		Question: "Fruit?",
+		Options:  []models.Option{{Label: "Apple"}, {Label: "Pear"}},
+		Status:   models.StatusOpen,
+	})
+	if err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+
+	resultsChannel := make(chan models.PollResults)
+	s := New(log.New(io.Discard, "", 0), resultsChannel, polls)
+	go s.handleResults()
+	t.Cleanup(func() { close(resultsChannel) })
+
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+	return s, resultsChannel, id.String(), "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// read returns the next message decoded into a map.
+func read(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestSubscribe(t *testing.T) {
+	_, resultsChannel, pollID, url := newTestServer(t)
+	conn := dial(t, url+pollPathPrefix+pollID)
+
+	if msg := read(t, conn); msg["poll_id"] != pollID || msg["question"] != "Fruit?" {
+		t.Fatalf("first message = %v, want a snapshot of poll %s", msg, pollID)
+	}
+
+	resultsChannel <- models.PollResults{
+		PollID:     pollID,
+		Votes:      map[string]int{"a": 3},
+		Status:     models.StatusOpen,
+		Visibility: models.ResultsAlways,
+	}
+	msg := read(t, conn)
+	if votes, _ := msg["votes"].(map[string]any); msg["poll_id"] != pollID || votes["a"] != 3.0 {
+		t.Fatalf("got %v, want the published results", msg)
+	}
+
+	for _, tt := range []struct {
+		msg, want string
+	}{
+		{`{"action":"subscribe","poll_id":"missing"}`, "poll missing not found"},
+		{`{"action":"subscribe"}`, "poll_id is required"},
+		{`{"action":"vote","poll_id":"x"}`, "unknown action: vote"},
+		{`not json`, "invalid message"},
+	} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.msg)); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+		if msg := read(t, conn); msg["error"] != tt.want {
+			t.Errorf("sending %s: got %v, want error %q", tt.msg, msg, tt.want)
+		}
+	}
+}
+
+func TestSlowClientIsDisconnected(t *testing.T) {
+	s, resultsChannel, pollID, url := newTestServer(t)
+	slow := dial(t, url+pollPathPrefix+pollID)
+	read(t, slow)
+	fast := dial(t, url+pollPathPrefix+pollID)
+	read(t, fast)
+
+	var slowClient *client
+	for _, c := range s.subscribers(pollID) {
+		if c.conn.RemoteAddr().String() == slow.LocalAddr().String() {
+			slowClient = c
+		}
+	}
+	if slowClient == nil {
+		t.Fatal("slow client is not subscribed")
+	}
+
+	// The slow client reads nothing, so once the socket's buffers are full
+	// its writer is stuck and its queue fills up.
+	big, err := json.Marshal(map[string]string{"padding": strings.Repeat("x", 1<<20)})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+	deadline := time.After(10 * time.Second)
+	for disconnected := false; !disconnected; {
+		select {
+		case <-slowClient.done:
+			disconnected = true
+		case <-deadline:
+			t.Fatal("slow client was not disconnected")
+		default:
+			s.deliver(slowClient, pollID, big)
+		}
+	}
+
+	resultsChannel <- models.PollResults{PollID: pollID, Status: models.StatusOpen, Visibility: models.ResultsAlways}
+	if msg := read(t, fast); msg["poll_id"] != pollID {
+		t.Fatalf("got %v, want results of poll %s", msg, pollID)
+	}
+}
+
+func TestResultsAreRedactedUntilVisible(t *testing.T) {
+	_, resultsChannel, pollID, url := newTestServer(t)
+	conn := dial(t, url+pollPathPrefix+pollID)
+	read(t, conn)
+
+	for _, tt := range []struct {
+		status    models.PollStatus
+		wantVotes bool
+	}{
+		{models.StatusOpen, false},
+		{models.StatusClosed, true},
+	} {
+		resultsChannel <- models.PollResults{
+			PollID:     pollID,
+			Votes:      map[string]int{"a": 3},
+			Status:     tt.status,
+			Visibility: models.ResultsAfterClose,
+		}
+		msg := read(t, conn)
+		votes, _ := msg["votes"].(map[string]any)
+		if msg["status"] != string(tt.status) || (votes["a"] == 3.0) != tt.wantVotes {
+			t.Fatalf("got %v for a %s after_close poll, want votes sent: %v", msg, tt.status, tt.wantVotes)
+		}
+	}
+}
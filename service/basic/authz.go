@@ -0,0 +1,148 @@
+package basic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"poll/auth"
+	"poll/models"
+	"poll/service"
+	"slices"
+)
+
+// roleOwner is held by the principal a poll was created by. Unlike the
+// roles of package auth it is never granted, so claiming it does nothing.
+const roleOwner = "owner"
+
+// permission is something a caller may be allowed to do with polls. Its
+// value completes "may not ..." in errors.
+type permission string
+
+const (
+	permCreate       permission = "create polls"
+	permUpdate       permission = "update"
+	permDelete       permission = "delete"
+	permChangeStatus permission = "change the status of"
+	permVote         permission = "vote in"
+	permViewResults  permission = "view the results of"
+	permViewEvents   permission = "view the vote events of"
+)
+
+// grants lists the roles holding each permission.
+var grants = map[permission][]string{
+	permCreate:       {auth.RoleAdmin},
+	permUpdate:       {auth.RoleAdmin, roleOwner},
+	permDelete:       {auth.RoleAdmin, roleOwner},
+	permChangeStatus: {auth.RoleAdmin, roleOwner},
+	permVote:         {auth.RoleAdmin, roleOwner, auth.RoleVoter},
+	permViewResults:  {auth.RoleAdmin, roleOwner, auth.RoleVoter, auth.RoleViewer},
+	permViewEvents:   {auth.RoleAdmin, roleOwner},
+}
+
+// authorize fails with service.ErrForbidden unless the caller may do what
+// the permission stands for with the poll, which is nil for permCreate.
+func authorize(ctx context.Context, perm permission, poll *models.Poll) error {
+	if allowed(ctx, perm, poll) {
+		return nil
+	}
+
+	// Anonymous callers may be allowed more once they authenticate.
+	err, caller := service.ErrForbidden, "anonymous callers"
+	if principal, ok := auth.FromContext(ctx); ok {
+		caller = principal.Subject
+	} else {
+		err = auth.ErrUnauthenticated
+	}
+	if poll == nil {
+		return fmt.Errorf("%w: %s may not %s", err, caller, perm)
+	}
+	return fmt.Errorf("%w: %s may not %s poll %s", err, caller, perm, poll.ID)
+}
+
+// allowed reports whether the caller may do what the permission stands for
+// with the poll. Anonymous callers of a server that authenticates have the
+// role it grants them, see auth.AnonymousRole. Other callers without a
+// principal are not restricted: they are the service itself, such as the
+// scheduler, or come through a server that does not authenticate.
+func allowed(ctx context.Context, perm permission, poll *models.Poll) bool {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		role, anonymous := auth.AnonymousRole(ctx)
+		return !anonymous || slices.Contains(grants[perm], role)
+	}
+
+	return slices.ContainsFunc(grants[perm], func(role string) bool {
+		if role == roleOwner {
+			return isOwner(principal, poll)
+		}
+		return principal.HasRole(role)
+	})
+}
+
+// isOwner reports whether the principal created the poll.
+func isOwner(principal *auth.Principal, poll *models.Poll) bool {
+	return poll != nil && poll.CreatedBy != "" && poll.CreatedBy == principal.Subject
+}
+
+// authorizeEvents fails with service.ErrForbidden unless the caller may
+// list the poll's vote events. These give its results away, so the caller
+// must be able to see those as well.
+func (s *PollService) authorizeEvents(ctx context.Context, poll *models.Poll) error {
+	if err := authorize(ctx, permViewEvents, poll); err != nil {
+		return err
+	}
+	if !s.resultsVisible(ctx, poll) {
+		return fmt.Errorf("%w: results of poll %s are hidden", service.ErrForbidden, poll.ID)
+	}
+	return nil
+}
+
+// redactResults hides the poll's vote counts from callers who may not see
+// them, see resultsVisible.
+func (s *PollService) redactResults(ctx context.Context, poll *models.Poll) {
+	if !s.resultsVisible(ctx, poll) {
+		poll.Votes = nil
+	}
+}
+
+// resultsVisible reports whether the caller may see the poll's vote counts.
+// Callers need a role permitting it, and unless they own the poll or are an
+// admin, they see what the poll's results visibility shows everyone else.
+// Callers without a principal are treated as anonymous readers here, and
+// results stay hidden if it cannot be told whether the caller voted, so
+// they are never revealed by accident.
+func (s *PollService) resultsVisible(ctx context.Context, poll *models.Poll) bool {
+	if !allowed(ctx, permViewResults, poll) {
+		return false
+	}
+	if principal, ok := auth.FromContext(ctx); ok && (principal.HasRole(auth.RoleAdmin) || isOwner(principal, poll)) {
+		return true
+	}
+
+	if poll.ResultsVisibility.Reveals(poll.Status, false) {
+		return true
+	}
+	voter := callerVoter(ctx)
+	if voter == "" || !poll.ResultsVisibility.Reveals(poll.Status, true) {
+		return false
+	}
+
+	voted, err := s.repo.HasVoted(ctx, poll.ID.String(), voter)
+	if err != nil {
+		log.Printf("failed to check ballot of %s in poll %s: %v", voter, poll.ID, err)
+		return false
+	}
+	return voted
+}
+
+// callerVoter returns the identity the caller votes under, if it is a
+// verified one: the subject they authenticated as or the voter ID of their
+// voter token, namespaced as by models.Vote.Voter.
+func callerVoter(ctx context.Context) string {
+	vote := models.Vote{}
+	if principal, ok := auth.FromContext(ctx); ok {
+		vote.Subject = principal.Subject
+	}
+	vote.VoterID, _ = auth.VoterIDFromContext(ctx)
+	return vote.Voter()
+}
@@ -0,0 +1,77 @@
+package basic
+
+import (
+	"context"
+	"poll/auth"
+	"poll/models"
+	"slices"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	poll := &models.Poll{CreatedBy: "owner"}
+	principal := func(subject string, roles ...string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: subject, Roles: roles})
+	}
+	anonymous := func(role string) context.Context {
+		return auth.WithAnonymousRole(context.Background(), role)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		allowed []permission
+	}{
+		{
+			name:    "service",
+			ctx:     context.Background(),
+			allowed: []permission{permCreate, permUpdate, permDelete, permChangeStatus, permVote, permViewResults, permViewEvents},
+		},
+		{
+			name:    "admin",
+			ctx:     principal("alice", auth.RoleAdmin),
+			allowed: []permission{permCreate, permUpdate, permDelete, permChangeStatus, permVote, permViewResults, permViewEvents},
+		},
+		{
+			name:    "owner",
+			ctx:     principal("owner"),
+			allowed: []permission{permUpdate, permDelete, permChangeStatus, permVote, permViewResults, permViewEvents},
+		},
+		{
+			name:    "voter",
+			ctx:     principal("bob", auth.RoleVoter),
+			allowed: []permission{permVote, permViewResults},
+		},
+		{
+			name:    "without roles",
+			ctx:     principal("bob"),
+			allowed: []permission{permVote, permViewResults},
+		},
+		{
+			name:    "viewer",
+			ctx:     principal("carol", auth.RoleViewer),
+			allowed: []permission{permViewResults},
+		},
+		{
+			name:    "anonymous viewer",
+			ctx:     anonymous(auth.RoleViewer),
+			allowed: []permission{permViewResults},
+		},
+		{
+			name:    "anonymous voter",
+			ctx:     anonymous(auth.RoleVoter),
+			allowed: []permission{permVote, permViewResults},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for perm := range grants {
+				want := slices.Contains(tt.allowed, perm)
+				if got := allowed(tt.ctx, perm, poll); got != want {
+					t.Errorf("may %s: got %v, want %v", perm, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package basic
+
+import (
+	"fmt"
+	"poll/models"
+	"poll/service"
+)
+
+// validateBallotSettings fills in the default ballot type and checks that
+// the choice limits fit the poll's options.
+func validateBallotSettings(poll *models.Poll) error {
+	switch poll.BallotType {
+	case "":
+		poll.BallotType = models.BallotSingle
+		fallthrough
+	case models.BallotSingle:
+		if poll.MinChoices != 0 || poll.MaxChoices != 0 {
+			return fmt.Errorf("%w: single-choice polls take no choice limits", service.ErrInvalidBallot)
+		}
+		return nil
+	case models.BallotMulti, models.BallotRanked:
+	default:
+		return fmt.Errorf("%w: unknown ballot type %s", service.ErrInvalidBallot, poll.BallotType)
+	}
+
+	minChoices, maxChoices := choiceLimits(poll)
+	if poll.MinChoices < 0 || poll.MaxChoices < 0 || minChoices > maxChoices || maxChoices > len(poll.Options) {
+		return fmt.Errorf("%w: choice limits must satisfy 1 <= min_choices <= max_choices <= number of options",
+			service.ErrInvalidBallot)
+	}
+
+	return nil
+}
+
+// choiceLimits returns how many options a voter must and may pick. Unset
+// limits default to at least one and at most all options.
+func choiceLimits(poll *models.Poll) (int, int) {
+	if poll.BallotType == models.BallotSingle || poll.BallotType == "" {
+		return 1, 1
+	}
+
+	minChoices, maxChoices := poll.MinChoices, poll.MaxChoices
+	if minChoices == 0 {
+		minChoices = 1
+	}
+	if maxChoices == 0 {
+		maxChoices = len(poll.Options)
+	}
+
+	return minChoices, maxChoices
+}
+
+// validateVote checks the vote against the poll's ballot type and returns
+// the picked options.
+func validateVote(poll *models.Poll, vote models.Vote) ([]string, error) {
+	choices := vote.Choices()
+
+	minChoices, maxChoices := choiceLimits(poll)
+	if len(choices) < minChoices || len(choices) > maxChoices {
+		if minChoices == maxChoices {
+			return nil, fmt.Errorf("%w: pick exactly %d option(s)", service.ErrInvalidBallot, minChoices)
+		}
+		return nil, fmt.Errorf("%w: pick between %d and %d options", service.ErrInvalidBallot, minChoices, maxChoices)
+	}
+
+	valid := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		valid[option.ID] = true
+	}
+
+	seen := make(map[string]bool, len(choices))
+	for _, choice := range choices {
+		if !valid[choice] {
+			return nil, fmt.Errorf("%w: %s", service.ErrInvalidOption, choice)
+		}
+		if seen[choice] {
+			return nil, fmt.Errorf("%w: option %s picked more than once", service.ErrInvalidBallot, choice)
+		}
+		seen[choice] = true
+	}
+
+	return choices, nil
+}
+
+// countBallots tallies vote counters the same way the repository does when
+// recording votes.
+func countBallots(poll *models.Poll, ballots map[string][]string) map[string]int {
+	votes := make(map[string]int)
+	for userID, choices := range ballots {
+		ballot := models.Ballot{
+			UserID:  userID,
+			Choices: choices,
+			Ranked:  poll.BallotType == models.BallotRanked,
+		}
+		for _, option := range ballot.Counted() {
+			votes[option]++
+		}
+	}
+	return votes
+}
+
+// instantRunoff tallies ranked ballots over the given option IDs round by
+// round. Each round counts every ballot for its highest-ranked option still
+// in the race. An option backed by a majority of the counted ballots, or the
+// last one left, wins; otherwise the options with the fewest votes are
+// eliminated. If all remaining options tie, the tally stops without a winner.
+func instantRunoff(options []string, ballots map[string][]string) []models.RunoffRound {
+	if len(ballots) == 0 {
+		return nil
+	}
+
+	active := make(map[string]bool, len(options))
+	for _, option := range options {
+		active[option] = true
+	}
+
+	var rounds []models.RunoffRound
+	for round := 1; len(active) > 0; round++ {
+		votes := make(map[string]int, len(active))
+		for option := range active {
+			votes[option] = 0
+		}
+
+		total := 0
+		for _, choices := range ballots {
+			for _, choice := range choices {
+				if active[choice] {
+					votes[choice]++
+					total++
+					break
+				}
+			}
+		}
+
+		result := models.RunoffRound{Round: round, Votes: votes}
+
+		leader, lowest := "", -1
+		for _, option := range options {
+			if !active[option] {
+				continue
+			}
+			if leader == "" || votes[option] > votes[leader] {
+				leader = option
+			}
+			if lowest == -1 || votes[option] < lowest {
+				lowest = votes[option]
+			}
+		}
+
+		if total > 0 && (votes[leader]*2 > total || len(active) == 1) {
+			result.Winner = leader
+			rounds = append(rounds, result)
+			break
+		}
+
+		var eliminated []string
+		for _, option := range options {
+			if active[option] && votes[option] == lowest {
+				eliminated = append(eliminated, option)
+			}
+		}
+
+		if len(eliminated) == len(active) {
+			rounds = append(rounds, result)
+			break
+		}
+
+		for _, option := range eliminated {
+			delete(active, option)
+		}
+		result.Eliminated = eliminated
+		rounds = append(rounds, result)
+	}
+
+	return rounds
+}
@@ -0,0 +1,295 @@
+package basic
+
+import (
+	"errors"
+	"poll/models"
+	"poll/service"
+	"reflect"
+	"testing"
+)
+
+// repeat returns n ballots with the choices, keyed by generated user IDs
+// starting with prefix.
+func repeat(prefix string, n int, choices ...string) map[string][]string {
+	ballots := make(map[string][]string, n)
+	for i := 0; i < n; i++ {
+		ballots[prefix+string(rune('a'+i))] = choices
+	}
+	return ballots
+}
+
+func merge(sets ...map[string][]string) map[string][]string {
+	ballots := make(map[string][]string)
+	for _, set := range sets {
+		for userID, choices := range set {
+			ballots[userID] = choices
+		}
+	}
+	return ballots
+}
+
+func TestInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		ballots map[string][]string
+		want    []models.RunoffRound
+	}{
+		{
+			name:    "no ballots",
+			options: []string{"A", "B"},
+		},
+		{
+			name:    "first round majority",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 3, "A", "B"), repeat("b", 2, "B")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 3, "B": 2, "C": 0}, Winner: "A"},
+			},
+		},
+		{
+			name:    "eliminated ballots transfer",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 4, "A"), repeat("b", 3, "B"), repeat("c", 2, "C", "B")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 4, "B": 3, "C": 2}, Eliminated: []string{"C"}},
+				{Round: 2, Votes: map[string]int{"A": 4, "B": 5}, Winner: "B"},
+			},
+		},
+		{
+			name:    "transfers skip eliminated preferences",
+			options: []string{"A", "B", "C", "D"},
+			ballots: merge(repeat("a", 5, "A"), repeat("b", 4, "B"), repeat("c", 3, "C", "A"), repeat("d", 2, "D", "C", "B")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 5, "B": 4, "C": 3, "D": 2}, Eliminated: []string{"D"}},
+				{Round: 2, Votes: map[string]int{"A": 5, "B": 4, "C": 5}, Eliminated: []string{"B"}},
+				{Round: 3, Votes: map[string]int{"A": 5, "C": 5}},
+			},
+		},
+		{
+			name:    "exhausted ballots leave the majority",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 4, "A"), repeat("b", 3, "B"), repeat("c", 2, "C")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 4, "B": 3, "C": 2}, Eliminated: []string{"C"}},
+				{Round: 2, Votes: map[string]int{"A": 4, "B": 3}, Winner: "A"},
+			},
+		},
+		{
+			name:    "options tied last are eliminated together",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 3, "A"), repeat("b", 2, "B", "C"), repeat("c", 2, "C", "B")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 3, "B": 2, "C": 2}, Eliminated: []string{"B", "C"}},
+				{Round: 2, Votes: map[string]int{"A": 3}, Winner: "A"},
+			},
+		},
+		{
+			name:    "options without votes are eliminated first",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 2, "A", "C"), repeat("b", 2, "B")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 2, "B": 2, "C": 0}, Eliminated: []string{"C"}},
+				{Round: 2, Votes: map[string]int{"A": 2, "B": 2}},
+			},
+		},
+		{
+			name:    "a tie of all options has no winner",
+			options: []string{"A", "B"},
+			ballots: merge(repeat("a", 2, "A", "B"), repeat("b", 2, "B", "A")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 2, "B": 2}},
+			},
+		},
+		{
+			name:    "the last option left wins without a majority",
+			options: []string{"A", "B", "C"},
+			ballots: merge(repeat("a", 2, "A"), repeat("b", 1, "B"), repeat("c", 1, "C")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 2, "B": 1, "C": 1}, Eliminated: []string{"B", "C"}},
+				{Round: 2, Votes: map[string]int{"A": 2}, Winner: "A"},
+			},
+		},
+		{
+			name:    "choices of removed options are skipped",
+			options: []string{"A", "B"},
+			ballots: merge(repeat("a", 2, "X", "B"), repeat("b", 1, "A")),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 1, "B": 2}, Winner: "B"},
+			},
+		},
+		{
+			name:    "only exhausted ballots",
+			options: []string{"A", "B"},
+			ballots: repeat("a", 2, "X"),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 0, "B": 0}},
+			},
+		},
+		{
+			name:    "single option",
+			options: []string{"A"},
+			ballots: repeat("a", 1, "A"),
+			want: []models.RunoffRound{
+				{Round: 1, Votes: map[string]int{"A": 1}, Winner: "A"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := instantRunoff(tt.options, tt.ballots)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rounds\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountBallots(t *testing.T) {
+	ballots := map[string][]string{
+		"u1": {"A", "B"},
+		"u2": {"B"},
+		"u3": {"C", "A"},
+	}
+
+	tests := []struct {
+		ballotType models.BallotType
+		want       map[string]int
+	}{
+		{models.BallotMulti, map[string]int{"A": 2, "B": 2, "C": 1}},
+		{models.BallotRanked, map[string]int{"A": 1, "B": 1, "C": 1}},
+	}
+	for _, tt := range tests {
+		got := countBallots(&models.Poll{BallotType: tt.ballotType}, ballots)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.ballotType, got, tt.want)
+		}
+	}
+}
+
+func TestValidateBallotSettings(t *testing.T) {
+	options := []models.Option{{ID: "A"}, {ID: "B"}, {ID: "C"}}
+
+	tests := []struct {
+		name     string
+		poll     models.Poll
+		wantType models.BallotType
+		wantErr  error
+	}{
+		{name: "default", poll: models.Poll{}, wantType: models.BallotSingle},
+		{name: "single", poll: models.Poll{BallotType: models.BallotSingle}, wantType: models.BallotSingle},
+		{name: "single with limits", poll: models.Poll{BallotType: models.BallotSingle, MaxChoices: 2}, wantErr: service.ErrInvalidBallot},
+		{name: "unset default with limits", poll: models.Poll{MinChoices: 1}, wantErr: service.ErrInvalidBallot},
+		{name: "multi", poll: models.Poll{BallotType: models.BallotMulti, MinChoices: 2, MaxChoices: 3}, wantType: models.BallotMulti},
+		{name: "ranked without limits", poll: models.Poll{BallotType: models.BallotRanked}, wantType: models.BallotRanked},
+		{name: "min above max", poll: models.Poll{BallotType: models.BallotMulti, MinChoices: 3, MaxChoices: 2}, wantErr: service.ErrInvalidBallot},
+		{name: "min above options", poll: models.Poll{BallotType: models.BallotMulti, MinChoices: 4}, wantErr: service.ErrInvalidBallot},
+		{name: "max above options", poll: models.Poll{BallotType: models.BallotRanked, MaxChoices: 4}, wantErr: service.ErrInvalidBallot},
+		{name: "negative", poll: models.Poll{BallotType: models.BallotMulti, MinChoices: -1}, wantErr: service.ErrInvalidBallot},
+		{name: "unknown type", poll: models.Poll{BallotType: "approval"}, wantErr: service.ErrInvalidBallot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := tt.poll
+			poll.Options = options
+
+			err := validateBallotSettings(&poll)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && poll.BallotType != tt.wantType {
+				t.Errorf("ballot type = %s, want %s", poll.BallotType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestValidateVote(t *testing.T) {
+	options := []models.Option{{ID: "A"}, {ID: "B"}, {ID: "C"}}
+
+	tests := []struct {
+		name    string
+		poll    models.Poll
+		vote    models.Vote
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "single",
+			poll: models.Poll{BallotType: models.BallotSingle},
+			vote: models.Vote{Option: "A"},
+			want: []string{"A"},
+		},
+		{
+			name:    "single with two choices",
+			poll:    models.Poll{BallotType: models.BallotSingle},
+			vote:    models.Vote{Options: []string{"A", "B"}},
+			wantErr: service.ErrInvalidBallot,
+		},
+		{
+			name:    "unknown option",
+			poll:    models.Poll{BallotType: models.BallotSingle},
+			vote:    models.Vote{Option: "X"},
+			wantErr: service.ErrInvalidOption,
+		},
+		{
+			name: "multi within limits",
+			poll: models.Poll{BallotType: models.BallotMulti, MinChoices: 2},
+			vote: models.Vote{Options: []string{"C", "A"}},
+			want: []string{"C", "A"},
+		},
+		{
+			name:    "multi below min",
+			poll:    models.Poll{BallotType: models.BallotMulti, MinChoices: 2},
+			vote:    models.Vote{Option: "A"},
+			wantErr: service.ErrInvalidBallot,
+		},
+		{
+			name:    "multi above max",
+			poll:    models.Poll{BallotType: models.BallotMulti, MaxChoices: 2},
+			vote:    models.Vote{Options: []string{"A", "B", "C"}},
+			wantErr: service.ErrInvalidBallot,
+		},
+		{
+			name: "ranked keeps the order",
+			poll: models.Poll{BallotType: models.BallotRanked},
+			vote: models.Vote{Options: []string{"B", "C", "A"}},
+			want: []string{"B", "C", "A"},
+		},
+		{
+			name:    "ranked with a repeated option",
+			poll:    models.Poll{BallotType: models.BallotRanked},
+			vote:    models.Vote{Options: []string{"B", "B"}},
+			wantErr: service.ErrInvalidBallot,
+		},
+		{
+			name:    "no choices",
+			poll:    models.Poll{BallotType: models.BallotMulti},
+			vote:    models.Vote{},
+			wantErr: service.ErrInvalidBallot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := tt.poll
+			poll.Options = options
+
+			got, err := validateVote(&poll, tt.vote)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("choices = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package basic
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"poll/models"
+	"poll/service"
+	"sort"
+)
+
+// prepareOptions gives new options an ID and numbers all options in display
+// order. Options are sorted by the order they were given, ties keeping their
+// position. An option that names an ID must be one of the known options, so
+// it keeps its votes.
+func prepareOptions(options []models.Option, known []models.Option) ([]models.Option, error) {
+	knownIDs := make(map[string]bool, len(known))
+	for _, option := range known {
+		knownIDs[option.ID] = true
+	}
+
+	prepared := make([]models.Option, len(options))
+	seen := make(map[string]bool, len(options))
+	for i, option := range options {
+		if option.ID == "" {
+			option.ID = uuid.NewString()
+		} else if !knownIDs[option.ID] {
+			return nil, fmt.Errorf("%w: %s", service.ErrInvalidOption, option.ID)
+		}
+
+		if seen[option.ID] {
+			return nil, fmt.Errorf("%w: %s listed more than once", service.ErrInvalidOption, option.ID)
+		}
+		seen[option.ID] = true
+
+		prepared[i] = option
+	}
+
+	sort.SliceStable(prepared, func(i, j int) bool {
+		return prepared[i].Order < prepared[j].Order
+	})
+	for i := range prepared {
+		prepared[i].Order = i
+	}
+
+	return prepared, nil
+}
+
+// removedOptions returns the IDs of the previous options that are missing
+// from the updated ones.
+func removedOptions(previous, updated []models.Option) []string {
+	kept := make(map[string]bool, len(updated))
+	for _, option := range updated {
+		kept[option.ID] = true
+	}
+
+	var removed []string
+	for _, option := range previous {
+		if !kept[option.ID] {
+			removed = append(removed, option.ID)
+		}
+	}
+
+	return removed
+}
+
+// sameOptions reports whether both lists hold the same option IDs with the
+// same labels, in any order. Descriptions and images may differ, as they do
+// not change what a vote for an option means.
+func sameOptions(a, b []models.Option) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	labels := make(map[string]string, len(a))
+	for _, option := range a {
+		labels[option.ID] = option.Label
+	}
+	for _, option := range b {
+		if label, ok := labels[option.ID]; !ok || label != option.Label {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resolveChoices maps the choices of a logged vote to option IDs. Votes cast
+// before options had IDs name options by label.
+func resolveChoices(poll *models.Poll, choices []string) []string {
+	ids := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		ids[option.ID] = true
+	}
+
+	resolved := make([]string, len(choices))
+	for i, choice := range choices {
+		if ids[choice] {
+			resolved[i] = choice
+		} else {
+			resolved[i] = models.LegacyOptionID(poll.ID, choice)
+		}
+	}
+
+	return resolved
+}
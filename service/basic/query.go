@@ -0,0 +1,50 @@
+package basic
+
+import (
+	"fmt"
+	"poll/models"
+	"poll/service"
+	"sort"
+	"strings"
+)
+
+// normalizeTags lower-cases and trims tags, dropping blanks and duplicates,
+// so tag filters match regardless of how a tag was spelled.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func validateQuery(query *models.PollQuery) error {
+	switch query.Status {
+	case "", models.StatusDraft, models.StatusOpen, models.StatusClosed, models.StatusArchived:
+	default:
+		return fmt.Errorf("%w: unknown status %s", service.ErrInvalidQuery, query.Status)
+	}
+
+	switch query.Sort {
+	case "":
+		query.Sort = models.SortCreated
+	case models.SortCreated, models.SortVotes:
+	default:
+		return fmt.Errorf("%w: unknown sort %s", service.ErrInvalidQuery, query.Sort)
+	}
+
+	if query.CreatedAfter != nil && query.CreatedBefore != nil && !query.CreatedAfter.Before(*query.CreatedBefore) {
+		return fmt.Errorf("%w: created_after must be before created_before", service.ErrInvalidQuery)
+	}
+
+	query.Tags = normalizeTags(query.Tags)
+	query.Search = strings.TrimSpace(query.Search)
+	return nil
+}
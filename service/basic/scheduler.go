@@ -0,0 +1,82 @@
+package basic
+
+import (
+	"context"
+	"errors"
+	"log"
+	"poll/models"
+	"poll/service"
+	"time"
+)
+
+// Scheduler opens and closes polls once their opens_at and closes_at times
+// are reached. Running it on several replicas is safe: a transition already
+// made by another replica is skipped.
+type Scheduler struct {
+	srv      *PollService
+	interval time.Duration
+}
+
+func NewScheduler(srv *PollService, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		srv:      srv,
+		interval: interval,
+	}
+}
+
+// Start checks for due polls every interval until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	pollIDs, err := s.srv.repo.DueScheduledPolls(ctx, now)
+	if err != nil {
+		log.Printf("scheduler: failed to list due polls: %v", err)
+		return
+	}
+
+	for _, pollID := range pollIDs {
+		if err := s.advance(ctx, pollID, now); err != nil {
+			log.Printf("scheduler: failed to advance poll %s: %v", pollID, err)
+		}
+	}
+}
+
+// advance applies every transition of the poll that is due by now, so a
+// poll whose whole voting window has passed is opened and closed in one go.
+func (s *Scheduler) advance(ctx context.Context, pollID string, now time.Time) error {
+	for {
+		poll, err := s.srv.getPoll(ctx, pollID)
+		if err != nil {
+			return err
+		}
+
+		at, ok := poll.NextTransition()
+		if !ok || at.After(now) {
+			return nil
+		}
+
+		next := models.StatusOpen
+		if poll.Status == models.StatusOpen {
+			next = models.StatusClosed
+		}
+
+		if _, err := s.srv.ChangeStatus(ctx, pollID, next); err != nil {
+			if errors.Is(err, service.ErrInvalidTransition) {
+				return nil
+			}
+			return err
+		}
+	}
+}
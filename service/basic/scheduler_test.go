@@ -0,0 +1,120 @@
+package basic
+
+import (
+	"context"
+	"poll/models"
+	"poll/repo/memory"
+	results "poll/service/results/memory"
+	"testing"
+	"time"
+)
+
+// newTestScheduler returns a scheduler over a memory repository, and the
+// results it publishes.
+func newTestScheduler(t *testing.T) (*Scheduler, <-chan models.PollResults) {
+	t.Helper()
+
+	publisher, err := results.New(16, results.PolicyDrop)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	t.Cleanup(func() { publisher.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	published, err := publisher.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	repo := memory.New()
+	return NewScheduler(NewService(repo, repo, publisher), time.Minute), published
+}
+
+func createScheduledPoll(t *testing.T, s *PollService, opensAt, closesAt time.Time) string {
+	t.Helper()
+
+	id, err := s.CreatePoll(context.Background(), models.Poll{
+		Question: "Fruit?",
+		Options:  []models.Option{{Label: "Apple"}, {Label: "Pear"}},
+		OpensAt:  &opensAt,
+		ClosesAt: &closesAt,
+	})
+	if err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	return id.String()
+}
+
+func expectStatus(t *testing.T, s *PollService, pollID string, want models.PollStatus) {
+	t.Helper()
+
+	poll, err := s.GetPoll(context.Background(), pollID)
+	if err != nil {
+		t.Fatalf("failed to get poll: %v", err)
+	}
+	if poll.Status != want {
+		t.Fatalf("poll is %s, want %s", poll.Status, want)
+	}
+}
+
+// expectPublished checks the results published since the last call, in order.
+func expectPublished(t *testing.T, published <-chan models.PollResults, pollID string, want ...models.PollStatus) {
+	t.Helper()
+
+	for _, status := range want {
+		select {
+		case r := <-published:
+			if r.PollID != pollID || r.Status != status || r.Final != (status == models.StatusClosed) {
+				t.Fatalf("got results %+v, want %s results of poll %s", r, status, pollID)
+			}
+		default:
+			t.Fatalf("no results published, want %s results of poll %s", status, pollID)
+		}
+	}
+	if len(published) > 0 {
+		t.Fatalf("got %d more results than expected", len(published))
+	}
+}
+
+func TestSchedulerOpensAndClosesPolls(t *testing.T) {
+	scheduler, published := newTestScheduler(t)
+	ctx := context.Background()
+	opensAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	closesAt := opensAt.Add(time.Hour)
+	pollID := createScheduledPoll(t, scheduler.srv, opensAt, closesAt)
+
+	scheduler.runDue(ctx, opensAt.Add(-time.Second))
+	expectStatus(t, scheduler.srv, pollID, models.StatusDraft)
+	expectPublished(t, published, pollID)
+
+	scheduler.runDue(ctx, opensAt)
+	expectStatus(t, scheduler.srv, pollID, models.StatusOpen)
+	expectPublished(t, published, pollID, models.StatusOpen)
+
+	scheduler.runDue(ctx, closesAt.Add(-time.Second))
+	expectStatus(t, scheduler.srv, pollID, models.StatusOpen)
+	expectPublished(t, published, pollID)
+
+	scheduler.runDue(ctx, closesAt)
+	expectStatus(t, scheduler.srv, pollID, models.StatusClosed)
+	expectPublished(t, published, pollID, models.StatusClosed)
+
+	// A closed poll is left alone.
+	scheduler.runDue(ctx, closesAt.Add(time.Hour))
+	expectStatus(t, scheduler.srv, pollID, models.StatusClosed)
+	expectPublished(t, published, pollID)
+}
+
+func TestSchedulerCatchesUpOnPassedWindows(t *testing.T) {
+	scheduler, published := newTestScheduler(t)
+	opensAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	closesAt := opensAt.Add(time.Hour)
+	pollID := createScheduledPoll(t, scheduler.srv, opensAt, closesAt)
+
+	// A poll whose whole voting window passed between two runs is opened
+	// and then closed, ending with its final results.
+	scheduler.runDue(context.Background(), closesAt.Add(time.Minute))
+	expectStatus(t, scheduler.srv, pollID, models.StatusClosed)
+	expectPublished(t, published, pollID, models.StatusOpen, models.StatusClosed)
+}
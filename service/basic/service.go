@@ -2,28 +2,57 @@ package basic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"log"
+	"poll/auth"
 	"poll/models"
 	"poll/repo"
+	"poll/service"
+	"slices"
+	"time"
 )
 
+// maxWriteAttempts bounds how often a write that did not name a version is
+// retried after racing another write of the same poll.
+const maxWriteAttempts = 3
+
+// exportPageSize is how many polls ExportResults loads at a time.
+const exportPageSize = 100
+
+// transitions lists the statuses a poll may move to from each status.
+var transitions = map[models.PollStatus][]models.PollStatus{
+	models.StatusDraft:  {models.StatusOpen, models.StatusArchived},
+	models.StatusOpen:   {models.StatusClosed},
+	models.StatusClosed: {models.StatusArchived},
+}
+
 type PollService struct {
-	repo           repo.RedisRepo
-	resultsChannel chan<- models.PollResults
+	repo      repo.Repository
+	events    repo.VoteEventLog
+	publisher service.ResultsPublisher
 }
 
-func NewService(repo repo.RedisRepo, resultsChannel chan<- models.PollResults) *PollService {
+func NewService(repo repo.Repository, events repo.VoteEventLog, publisher service.ResultsPublisher) *PollService {
 	return &PollService{
-		repo:           repo,
-		resultsChannel: resultsChannel,
+		repo:      repo,
+		events:    events,
+		publisher: publisher,
 	}
 }
 
+// CreatePoll creates the poll. An authenticated caller becomes its owner;
+// only admins may create polls on behalf of someone else.
 func (s *PollService) CreatePoll(ctx context.Context, poll models.Poll) (uuid.UUID, error) {
-	pollID := uuid.New()
-
-	poll.ID = pollID
+	if err := authorize(ctx, permCreate, nil); err != nil {
+		return uuid.Nil, err
+	}
+	poll, err := preparePoll(ctx, poll)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	pollID := poll.ID
 
 	existingPoll, err := s.repo.GetPoll(ctx, pollID.String())
 	if err == nil && existingPoll != nil {
@@ -38,85 +67,595 @@ func (s *PollService) CreatePoll(ctx context.Context, poll models.Poll) (uuid.UU
 	return pollID, nil
 }
 
+// ImportPolls creates the polls the way CreatePoll does, storing all that
+// are valid in one transaction and reporting the outcome for each. With
+// atomic set, nothing is stored unless every poll is valid; with dryRun set,
+// the polls are only validated.
+func (s *PollService) ImportPolls(ctx context.Context, polls []models.Poll, atomic, dryRun bool) ([]service.ImportResult, error) {
+	if err := authorize(ctx, permCreate, nil); err != nil {
+		return nil, err
+	}
+
+	results := make([]service.ImportResult, len(polls))
+	var valid []models.Poll
+	var rows []int
+	for i, poll := range polls {
+		prepared, err := preparePoll(ctx, poll)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, prepared)
+		rows = append(rows, i)
+	}
+
+	if dryRun || len(valid) == 0 || (atomic && len(valid) < len(polls)) {
+		return results, nil
+	}
+	if err := s.repo.CreatePolls(ctx, valid); err != nil {
+		return nil, fmt.Errorf("error importing polls: %w", err)
+	}
+
+	for i, row := range rows {
+		results[row].PollID = valid[i].ID
+	}
+	return results, nil
+}
+
+// preparePoll validates a poll the caller may create and returns it ready
+// to be stored under a new ID.
+func preparePoll(ctx context.Context, poll models.Poll) (models.Poll, error) {
+	if principal, ok := auth.FromContext(ctx); ok {
+		if poll.CreatedBy == "" {
+			poll.CreatedBy = principal.Subject
+		} else if poll.CreatedBy != principal.Subject && !principal.HasRole(auth.RoleAdmin) {
+			return models.Poll{}, fmt.Errorf("%w: %s may not create polls for %s", service.ErrForbidden, principal.Subject, poll.CreatedBy)
+		}
+	}
+
+	poll.ID = uuid.New()
+	poll.CreatedAt = time.Now().UTC()
+	poll.Tags = normalizeTags(poll.Tags)
+
+	switch poll.Status {
+	case "":
+		poll.Status = models.StatusDraft
+	case models.StatusDraft, models.StatusOpen:
+	default:
+		return models.Poll{}, fmt.Errorf("%w: polls cannot be created as %s", service.ErrInvalidStatus, poll.Status)
+	}
+
+	if err := validateSchedule(poll); err != nil {
+		return models.Poll{}, err
+	}
+	if err := validateVisibility(&poll); err != nil {
+		return models.Poll{}, err
+	}
+	if err := validateBallotSettings(&poll); err != nil {
+		return models.Poll{}, err
+	}
+
+	options, err := prepareOptions(poll.Options, nil)
+	if err != nil {
+		return models.Poll{}, err
+	}
+	poll.Options = options
+
+	return poll, nil
+}
+
+// GetPoll returns the poll, without its vote counts if the caller may not
+// see them.
 func (s *PollService) GetPoll(ctx context.Context, pollID string) (*models.Poll, error) {
-	poll, err := s.repo.GetPoll(ctx, pollID)
+	poll, err := s.getPoll(ctx, pollID)
 	if err != nil {
+		return nil, err
+	}
+
+	s.redactResults(ctx, poll)
+	return poll, nil
+}
+
+// getPoll loads the poll, reporting a missing one as service.ErrPollNotFound.
+func (s *PollService) getPoll(ctx context.Context, pollID string) (*models.Poll, error) {
+	poll, err := s.repo.GetPoll(ctx, pollID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, pollNotFound(pollID)
+	} else if err != nil {
 		return nil, fmt.Errorf("error retrieving poll: %w", err)
 	}
 	return poll, nil
 }
 
-func (s *PollService) ListPolls(ctx context.Context) ([]models.Poll, error) {
-	polls, err := s.repo.ListPolls(ctx)
+func pollNotFound(pollID string) error {
+	return fmt.Errorf("%w: %s", service.ErrPollNotFound, pollID)
+}
+
+func versionConflict(pollID string, current int64) error {
+	return fmt.Errorf("%w: poll %s is at version %d", service.ErrVersionConflict, pollID, current)
+}
+
+// updateError translates a failed repository update of the poll.
+func updateError(pollID string, err error) error {
+	if errors.Is(err, repo.ErrNotFound) {
+		return pollNotFound(pollID)
+	} else if errors.Is(err, repo.ErrVersionConflict) {
+		return fmt.Errorf("%w: %v", service.ErrVersionConflict, err)
+	}
+	return fmt.Errorf("error updating poll: %w", err)
+}
+
+// GetResults returns the poll's current results, without vote counts if the
+// caller may not see them.
+func (s *PollService) GetResults(ctx context.Context, pollID string) (*models.PollResults, error) {
+	poll, err := s.getPoll(ctx, pollID)
 	if err != nil {
+		return nil, err
+	}
+
+	results, _, err := s.visibleResults(ctx, poll)
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// visibleResults returns the poll's current results, without vote counts if
+// the caller may not see them, and whether they may.
+func (s *PollService) visibleResults(ctx context.Context, poll *models.Poll) (models.PollResults, bool, error) {
+	if !s.resultsVisible(ctx, poll) {
+		results := poll.Results()
+		results.Redact()
+		return results, false, nil
+	}
+
+	results, err := s.results(ctx, poll)
+	if err != nil {
+		return models.PollResults{}, false, err
+	}
+	return results, true, nil
+}
+
+// ExportResults passes the results of every poll matching the query to fn,
+// in the query's order, as GetResults returns them. With events set, polls
+// whose vote events the caller may list and whose results they may see come
+// with their vote events. It stops at the first error fn returns.
+func (s *PollService) ExportResults(ctx context.Context, query models.PollQuery, events bool, fn func(models.PollResults, []models.VoteEvent) error) error {
+	if err := validateQuery(&query); err != nil {
+		return err
+	}
+	query.Cursor = ""
+	query.Limit = exportPageSize
+
+	for {
+		page, err := s.repo.ListPolls(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error listing polls: %w", err)
+		}
+
+		for i := range page.Polls {
+			poll := &page.Polls[i]
+			results, visible, err := s.visibleResults(ctx, poll)
+			if err != nil {
+				return err
+			}
+
+			var pollEvents []models.VoteEvent
+			if events && visible && allowed(ctx, permViewEvents, poll) {
+				pollEvents, err = s.events.ListVoteEvents(ctx, poll.ID.String())
+				if err != nil {
+					return fmt.Errorf("error listing vote events: %w", err)
+				}
+			}
+
+			if err := fn(results, pollEvents); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// results returns the poll's current results, including the runoff rounds
+// of ranked polls.
+func (s *PollService) results(ctx context.Context, poll *models.Poll) (models.PollResults, error) {
+	results := poll.Results()
+	if poll.BallotType != models.BallotRanked {
+		return results, nil
+	}
+
+	ballots, err := s.repo.ListBallots(ctx, poll.ID.String())
+	if err != nil {
+		return models.PollResults{}, fmt.Errorf("error listing ballots: %w", err)
+	}
+	results.Rounds = instantRunoff(models.OptionIDs(poll.Options), ballots)
+
+	return results, nil
+}
+
+// publish pushes the poll's current results to live subscribers. Failures
+// are only logged, as the change being reported is already stored.
+func (s *PollService) publish(ctx context.Context, poll *models.Poll, final bool) {
+	results, err := s.results(ctx, poll)
+	if err != nil {
+		log.Printf("failed to build results for poll %s: %v", poll.ID, err)
+		return
+	}
+
+	results.Final = final
+	if err := s.publisher.Publish(ctx, results); err != nil {
+		log.Printf("failed to publish results for poll %s: %v", poll.ID, err)
+	}
+}
+
+func (s *PollService) ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error) {
+	if err := validateQuery(&query); err != nil {
+		return nil, err
+	}
+
+	page, err := s.repo.ListPolls(ctx, query)
+	if errors.Is(err, repo.ErrInvalidCursor) {
+		return nil, service.ErrInvalidCursor
+	} else if err != nil {
 		return nil, fmt.Errorf("error listing polls: %w", err)
 	}
-	return polls, nil
+
+	for i := range page.Polls {
+		s.redactResults(ctx, &page.Polls[i])
+	}
+	return page, nil
 }
 
-func (s *PollService) DeletePoll(ctx context.Context, pollID string) error {
-	_, err := s.repo.GetPoll(ctx, pollID)
+// DeletePoll deletes the poll if it is at the given version, or at any
+// version if that is 0.
+func (s *PollService) DeletePoll(ctx context.Context, pollID string, version int64) error {
+	existingPoll, err := s.getPoll(ctx, pollID)
 	if err != nil {
-		return fmt.Errorf("error retrieving poll before deletion: %w", err)
+		return err
+	}
+	if err := authorize(ctx, permDelete, existingPoll); err != nil {
+		return err
+	}
+	if version != 0 && version != existingPoll.Version {
+		return versionConflict(pollID, existingPoll.Version)
 	}
 
-	return s.repo.DeletePoll(ctx, pollID)
+	if err := s.repo.DeletePoll(ctx, pollID, version); errors.Is(err, repo.ErrNotFound) {
+		return pollNotFound(pollID)
+	} else if errors.Is(err, repo.ErrVersionConflict) {
+		return fmt.Errorf("%w: %v", service.ErrVersionConflict, err)
+	} else if err != nil {
+		return fmt.Errorf("error deleting poll: %w", err)
+	}
+
+	return nil
 }
 
-func (s *PollService) UpdatePoll(ctx context.Context, pollID string, poll models.Poll) error {
-	existingPoll, err := s.repo.GetPoll(ctx, pollID)
+// UpdatePoll replaces the poll's details and returns the updated poll. A
+// non-zero poll.Version must be the poll's current version. Without one, the
+// update is retried when it races another write, as it does not depend on
+// what that write changed.
+func (s *PollService) UpdatePoll(ctx context.Context, pollID string, poll models.Poll) (*models.Poll, error) {
+	for attempt := 1; ; attempt++ {
+		updated, err := s.updatePoll(ctx, pollID, poll)
+		if errors.Is(err, service.ErrVersionConflict) && poll.Version == 0 && attempt < maxWriteAttempts {
+			continue
+		}
+		return updated, err
+	}
+}
+
+func (s *PollService) updatePoll(ctx context.Context, pollID string, poll models.Poll) (*models.Poll, error) {
+	existingPoll, err := s.getPoll(ctx, pollID)
 	if err != nil {
-		return fmt.Errorf("error retrieving poll before update: %w", err)
+		return nil, err
 	}
-	if existingPoll == nil {
-		return fmt.Errorf("poll with ID %s does not exist", pollID)
+	if err := authorize(ctx, permUpdate, existingPoll); err != nil {
+		return nil, err
+	}
+	if poll.Version != 0 && poll.Version != existingPoll.Version {
+		return nil, versionConflict(pollID, existingPoll.Version)
+	}
+
+	options, err := prepareOptions(poll.Options, existingPoll.Options)
+	if err != nil {
+		return nil, err
+	}
+	poll.Options = options
+
+	// The status only changes through ChangeStatus.
+	poll.Status = existingPoll.Status
+	poll.ID = existingPoll.ID
+	poll.CreatedAt = existingPoll.CreatedAt
+	poll.CreatedBy = existingPoll.CreatedBy
+	poll.Version = existingPoll.Version
+	poll.Tags = normalizeTags(poll.Tags)
+	if poll.Status == models.StatusArchived {
+		return nil, fmt.Errorf("%w: poll is archived", service.ErrPollLocked)
+	}
+	if err := validateSchedule(poll); err != nil {
+		return nil, err
+	}
+	if err := validateVisibility(&poll); err != nil {
+		return nil, err
+	}
+	if err := validateBallotSettings(&poll); err != nil {
+		return nil, err
+	}
+	if poll.Status != models.StatusDraft && !sameBallot(poll, *existingPoll) {
+		return nil, fmt.Errorf("%w: options and ballot settings cannot change once voting has started", service.ErrPollLocked)
+	}
+	if err := s.checkRemovedOptions(ctx, pollID, removedOptions(existingPoll.Options, poll.Options)); err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.UpdatePoll(ctx, pollID, poll); err != nil {
-		return fmt.Errorf("error updating poll: %w", err)
+		return nil, updateError(pollID, err)
+	}
+
+	poll.Version++
+	poll.Votes = existingPoll.Votes
+	s.redactResults(ctx, &poll)
+	return &poll, nil
+}
+
+func (s *PollService) ChangeStatus(ctx context.Context, pollID string, status models.PollStatus) (*models.Poll, error) {
+	for attempt := 1; ; attempt++ {
+		poll, err := s.changeStatus(ctx, pollID, status)
+		if errors.Is(err, service.ErrVersionConflict) && attempt < maxWriteAttempts {
+			continue
+		}
+		return poll, err
+	}
+}
+
+func (s *PollService) changeStatus(ctx context.Context, pollID string, status models.PollStatus) (*models.Poll, error) {
+	poll, err := s.getPoll(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, permChangeStatus, poll); err != nil {
+		return nil, err
 	}
 
+	if !slices.Contains(transitions[poll.Status], status) {
+		return nil, fmt.Errorf("%w: %s to %s", service.ErrInvalidTransition, poll.Status, status)
+	}
+
+	poll.Status = status
+	if err := s.repo.UpdatePoll(ctx, pollID, *poll); err != nil {
+		return nil, updateError(pollID, err)
+	}
+	poll.Version++
+
+	s.publish(ctx, poll, status == models.StatusClosed)
+
+	s.redactResults(ctx, poll)
+	return poll, nil
+}
+
+// Vote records the vote. Voters are told apart by who they authenticated
+// as, then by the voter ID of their voter token, and only then by the user
+// ID they chose themselves, see models.Vote.Voter. A user ID a verified
+// voter of the poll goes by is turned away, so that nobody can pass for
+// them in the poll's vote events.
+func (s *PollService) Vote(ctx context.Context, pollID string, vote models.Vote) error {
+	vote.Subject = ""
+	if principal, ok := auth.FromContext(ctx); ok {
+		vote.Subject = principal.Subject
+	}
+	voter := vote.Voter()
+	if voter == "" {
+		return &service.ValidationError{Field: "user_id", Message: "is required"}
+	}
+
+	poll, err := s.getPoll(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, permVote, poll); err != nil {
+		return err
+	}
+
+	if !acceptsVotes(poll, time.Now()) {
+		return service.ErrPollClosed
+	}
+
+	choices, err := validateVote(poll, vote)
+	if err != nil {
+		return err
+	}
+	if vote.Subject == "" && vote.VoterID == "" {
+		if err := s.checkUserID(ctx, pollID, vote.UserID); err != nil {
+			return err
+		}
+	}
+
+	ballot := models.Ballot{
+		UserID:  voter,
+		Choices: choices,
+		Ranked:  poll.BallotType == models.BallotRanked,
+	}
+	event := models.VoteEvent{
+		PollID:    pollID,
+		UserID:    voter,
+		Timestamp: time.Now().UTC(),
+		ClientIP:  vote.ClientIP,
+		UserAgent: vote.UserAgent,
+	}
+	if poll.BallotType == models.BallotSingle {
+		event.Option = choices[0]
+	} else {
+		event.Options = choices
+	}
+
+	votes, err := s.repo.RecordVote(ctx, pollID, ballot, poll.AllowVoteChange, event)
+	if errors.Is(err, repo.ErrAlreadyVoted) {
+		return service.ErrAlreadyVoted
+	} else if errors.Is(err, repo.ErrNotFound) {
+		return pollNotFound(pollID)
+	} else if err != nil {
+		return fmt.Errorf("error recording vote: %w", err)
+	}
+
+	poll.Votes = votes
+	s.publish(ctx, poll, false)
+
 	return nil
 }
 
-func (s *PollService) Vote(ctx context.Context, pollID string, option string) error {
-	poll, err := s.repo.GetPoll(ctx, pollID)
+// checkUserID fails with a validation error if a verified voter of the poll
+// goes by the user ID.
+func (s *PollService) checkUserID(ctx context.Context, pollID, userID string) error {
+	for _, prefix := range []string{models.VoterSubject, models.VoterToken} {
+		voted, err := s.repo.HasVoted(ctx, pollID, prefix+userID)
+		if err != nil {
+			return fmt.Errorf("failed to check ballot of %s%s: %w", prefix, userID, err)
+		}
+		if voted {
+			return &service.ValidationError{Field: "user_id", Message: "belongs to a verified voter"}
+		}
+	}
+	return nil
+}
+
+func (s *PollService) ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error) {
+	poll, err := s.getPoll(ctx, pollID)
 	if err != nil {
-		return fmt.Errorf("error retrieving poll: %w", err)
+		return nil, err
+	}
+	if err := s.authorizeEvents(ctx, poll); err != nil {
+		return nil, err
 	}
-	if poll == nil {
-		return fmt.Errorf("poll with ID %s does not exist", pollID)
+
+	events, err := s.events.ListVoteEvents(ctx, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing vote events: %w", err)
 	}
 
-	validOption := false
-	for _, o := range poll.Options {
-		if o == option {
-			validOption = true
-			break
+	return events, nil
+}
+
+// ReplayVotes recomputes the poll's vote counts from its event log. Only the
+// latest event of every user counts, which matches how vote changes are
+// recorded. With apply set, the rebuilt counts replace the stored ones and
+// are published to live subscribers.
+func (s *PollService) ReplayVotes(ctx context.Context, pollID string, apply bool) (*models.VoteReplay, error) {
+	poll, err := s.getPoll(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeEvents(ctx, poll); err != nil {
+		return nil, err
+	}
+	if apply {
+		if err := authorize(ctx, permUpdate, poll); err != nil {
+			return nil, err
 		}
 	}
 
-	if !validOption {
-		return fmt.Errorf("invalid option: %s", option)
+	events, err := s.events.ListVoteEvents(ctx, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing vote events: %w", err)
 	}
 
-	if _, exists := poll.Votes[option]; !exists {
-		poll.Votes[option] = 0
+	ballots := make(map[string][]string)
+	for _, event := range events {
+		ballots[event.UserID] = resolveChoices(poll, event.Choices())
 	}
-	poll.Votes[option]++
+	votes := countBallots(poll, ballots)
 
-	if err := s.repo.UpdatePoll(ctx, pollID, *poll); err != nil {
-		return fmt.Errorf("error updating poll: %w", err)
+	replay := &models.VoteReplay{
+		PollID: pollID,
+		Events: len(events),
+		Votes:  votes,
+	}
+	if !apply {
+		return replay, nil
 	}
 
-	pollResults := models.PollResults{
-		PollID:   pollID,
-		Question: poll.Question,
-		Options:  poll.Options,
-		Votes:    poll.Votes,
+	if err := s.repo.ReplaceVotes(ctx, pollID, votes, ballots); errors.Is(err, repo.ErrNotFound) {
+		return nil, pollNotFound(pollID)
+	} else if err != nil {
+		return nil, fmt.Errorf("error replacing votes: %w", err)
 	}
+	replay.Applied = true
 
-	s.resultsChannel <- pollResults
+	poll.Votes = votes
+	s.publish(ctx, poll, false)
 
+	return replay, nil
+}
+
+// checkRemovedOptions refuses to remove options that appear on any ballot,
+// at whatever rank, so no vote is silently dropped.
+func (s *PollService) checkRemovedOptions(ctx context.Context, pollID string, removed []string) error {
+	if len(removed) == 0 {
+		return nil
+	}
+
+	ballots, err := s.repo.ListBallots(ctx, pollID)
+	if err != nil {
+		return fmt.Errorf("error listing ballots: %w", err)
+	}
+	for _, choices := range ballots {
+		for _, choice := range choices {
+			if slices.Contains(removed, choice) {
+				return fmt.Errorf("%w: %s", service.ErrOptionInUse, choice)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameBallot reports whether two versions of a poll take the same ballots,
+// which must hold once voting has started. Only the options' descriptions,
+// images and display order may still change.
+func sameBallot(a, b models.Poll) bool {
+	return sameOptions(a.Options, b.Options) &&
+		a.BallotType == b.BallotType &&
+		a.MinChoices == b.MinChoices &&
+		a.MaxChoices == b.MaxChoices
+}
+
+func validateSchedule(poll models.Poll) error {
+	if poll.OpensAt != nil && poll.ClosesAt != nil && !poll.ClosesAt.After(*poll.OpensAt) {
+		return service.ErrInvalidSchedule
+	}
+	return nil
+}
+
+// validateVisibility checks the poll's results visibility, which defaults to
+// showing results always.
+func validateVisibility(poll *models.Poll) error {
+	switch poll.ResultsVisibility {
+	case "":
+		poll.ResultsVisibility = models.ResultsAlways
+	case models.ResultsAlways, models.ResultsAfterVote, models.ResultsAfterClose, models.ResultsOwnerOnly:
+	default:
+		return &service.ValidationError{Field: "results_visibility", Message: "must be always, after_vote, after_close or owner_only"}
+	}
 	return nil
 }
+
+// acceptsVotes reports whether the poll is open and now falls within its
+// voting window. The window is checked here as well because the scheduler
+// only flips statuses periodically.
+func acceptsVotes(poll *models.Poll, now time.Time) bool {
+	if poll.Status != models.StatusOpen {
+		return false
+	}
+	if poll.OpensAt != nil && now.Before(*poll.OpensAt) {
+		return false
+	}
+	if poll.ClosesAt != nil && !now.Before(*poll.ClosesAt) {
+		return false
+	}
+	return true
+}
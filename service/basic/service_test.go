@@ -0,0 +1,155 @@
+package basic
+
+import (
+	"context"
+	"errors"
+	"poll/auth"
+	"poll/models"
+	"poll/repo/memory"
+	"poll/service"
+	results "poll/service/results/memory"
+	"slices"
+	"testing"
+)
+
+func newTestService(t *testing.T) *PollService {
+	t.Helper()
+
+	publisher, err := results.New(16, results.PolicyCoalesce)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	repo := memory.New()
+	return NewService(repo, repo, publisher)
+}
+
+func TestVoteIdentities(t *testing.T) {
+	s := newTestService(t)
+	anonymous := context.Background()
+	alice := auth.WithPrincipal(anonymous, &auth.Principal{Subject: "alice"})
+	bob := auth.WithPrincipal(anonymous, &auth.Principal{Subject: "bob"})
+	token := auth.WithVoterID(anonymous, "carol")
+
+	id, err := s.CreatePoll(anonymous, models.Poll{
+		Question:        "Fruit?",
+		Options:         []models.Option{{Label: "Apple"}, {Label: "Pear"}},
+		Status:          models.StatusOpen,
+		AllowVoteChange: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	pollID := id.String()
+	poll, err := s.GetPoll(anonymous, pollID)
+	if err != nil {
+		t.Fatalf("failed to get poll: %v", err)
+	}
+	apple, pear := poll.Options[0].ID, poll.Options[1].ID
+
+	vote := func(ctx context.Context, v models.Vote) error {
+		return s.Vote(ctx, pollID, v)
+	}
+	if err := vote(alice, models.Vote{Option: apple}); err != nil {
+		t.Fatalf("authenticated vote failed: %v", err)
+	}
+	if err := vote(token, models.Vote{Option: apple, VoterID: "carol"}); err != nil {
+		t.Fatalf("voter token vote failed: %v", err)
+	}
+	if err := vote(anonymous, models.Vote{Option: pear, UserID: "bob"}); err != nil {
+		t.Fatalf("anonymous vote failed: %v", err)
+	}
+
+	var validationErr *service.ValidationError
+	for _, userID := range []string{"alice", "carol"} {
+		err := vote(anonymous, models.Vote{Option: pear, UserID: userID})
+		if !errors.As(err, &validationErr) || validationErr.Field != "user_id" {
+			t.Fatalf("anonymous vote as %s = %v, want an invalid user_id", userID, err)
+		}
+	}
+	if err := vote(anonymous, models.Vote{Option: pear, UserID: "mallory", Subject: "alice"}); err != nil {
+		t.Fatalf("anonymous vote claiming a subject failed: %v", err)
+	}
+	if err := vote(bob, models.Vote{Option: apple}); err != nil {
+		t.Fatalf("authenticated vote after an anonymous one under the same name failed: %v", err)
+	}
+
+	poll, err = s.GetPoll(anonymous, pollID)
+	if err != nil {
+		t.Fatalf("failed to get poll: %v", err)
+	}
+	if poll.Votes[apple] != 3 || poll.Votes[pear] != 2 {
+		t.Fatalf("votes = %v, want 3 for %s and 2 for %s", poll.Votes, apple, pear)
+	}
+
+	events, err := s.ListVoteEvents(anonymous, pollID)
+	if err != nil {
+		t.Fatalf("failed to list vote events: %v", err)
+	}
+	want := []string{"sub:alice", "vt:carol", "uid:bob", "uid:mallory", "sub:bob"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.UserID != want[i] {
+			t.Errorf("event %d votes as %s, want %s", i, event.UserID, want[i])
+		}
+	}
+}
+
+func TestUpdatePollLocksOptions(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	id, err := s.CreatePoll(ctx, models.Poll{
+		Question: "Agree?",
+		Options:  []models.Option{{Label: "Yes"}, {Label: "Maybe"}},
+		Status:   models.StatusOpen,
+	})
+	if err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	pollID := id.String()
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		t.Fatalf("failed to get poll: %v", err)
+	}
+	if err := s.Vote(ctx, pollID, models.Vote{Option: poll.Options[0].ID, UserID: "u1"}); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+
+	edit := func(change func(options []models.Option) []models.Option) error {
+		update := *poll
+		update.Version = 0
+		update.Options = change(slices.Clone(poll.Options))
+		_, err := s.UpdatePoll(ctx, pollID, update)
+		return err
+	}
+	relabel := func(options []models.Option) []models.Option {
+		options[0].Label = "No"
+		return options
+	}
+	add := func(options []models.Option) []models.Option {
+		return append(options, models.Option{Label: "Never"})
+	}
+	describe := func(options []models.Option) []models.Option {
+		options[0].Description = "Fully agree"
+		return options
+	}
+
+	for _, status := range []models.PollStatus{models.StatusOpen, models.StatusClosed} {
+		if status == models.StatusClosed {
+			if _, err := s.ChangeStatus(ctx, pollID, status); err != nil {
+				t.Fatalf("failed to close poll: %v", err)
+			}
+		}
+		if err := edit(relabel); !errors.Is(err, service.ErrPollLocked) {
+			t.Fatalf("relabelling an option of a %s poll: got %v, want %v", status, err, service.ErrPollLocked)
+		}
+		if err := edit(add); !errors.Is(err, service.ErrPollLocked) {
+			t.Fatalf("adding an option to a %s poll: got %v, want %v", status, err, service.ErrPollLocked)
+		}
+		if err := edit(describe); err != nil {
+			t.Fatalf("describing an option of a %s poll: %v", status, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrForbidden         = errors.New("forbidden")
+	ErrAlreadyVoted      = errors.New("user has already voted")
+	ErrPollClosed        = errors.New("poll is not open for voting")
+	ErrPollLocked        = errors.New("poll is locked")
+	ErrOptionInUse       = errors.New("option has votes and cannot be removed")
+	ErrVersionConflict   = errors.New("poll was modified by someone else")
+	ErrInvalidStatus     = errors.New("invalid poll status")
+	ErrInvalidTransition = errors.New("invalid poll status transition")
+	ErrInvalidSchedule   = errors.New("closes_at must be after opens_at")
+	ErrInvalidBallot     = errors.New("invalid ballot")
+	ErrInvalidOption     = errors.New("invalid option")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidQuery      = errors.New("invalid query")
+)
+
+// ValidationError reports a request field that holds an unacceptable value.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors collects every invalid field of a request, so clients can
+// fix them all at once.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"poll/models"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to results published while a
+// subscriber's buffer is full.
+type OverflowPolicy string
+
+const (
+	// PolicyDrop discards results that do not fit into the buffer.
+	PolicyDrop OverflowPolicy = "drop"
+	// PolicyCoalesce keeps only the latest pending results per poll until the
+	// subscriber catches up. Results carry full totals, so nothing is lost.
+	PolicyCoalesce OverflowPolicy = "coalesce"
+)
+
+// Publisher delivers results to subscribers within the current process.
+type Publisher struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	bufferSize  int
+	policy      OverflowPolicy
+	closed      bool
+}
+
+func New(bufferSize int, policy OverflowPolicy) (*Publisher, error) {
+	if policy != PolicyDrop && policy != PolicyCoalesce {
+		return nil, fmt.Errorf("unknown overflow policy: %s", policy)
+	}
+
+	return &Publisher{
+		subscribers: make(map[*subscriber]struct{}),
+		bufferSize:  bufferSize,
+		policy:      policy,
+	}, nil
+}
+
+func (p *Publisher) Publish(_ context.Context, results models.PollResults) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("publisher is closed")
+	}
+
+	for sub := range p.subscribers {
+		sub.push(results)
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel of results published from now on. The channel
+// is closed when ctx is done or the publisher is closed.
+func (p *Publisher) Subscribe(ctx context.Context) (<-chan models.PollResults, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("publisher is closed")
+	}
+
+	sub := newSubscriber(p.bufferSize, p.policy)
+	p.subscribers[sub] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.unsubscribe(sub)
+		case <-sub.done:
+		}
+	}()
+
+	return sub.out, nil
+}
+
+func (p *Publisher) unsubscribe(sub *subscriber) {
+	p.mu.Lock()
+	_, ok := p.subscribers[sub]
+	delete(p.subscribers, sub)
+	p.mu.Unlock()
+
+	if ok {
+		sub.close()
+	}
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	subscribers := p.subscribers
+	p.subscribers = make(map[*subscriber]struct{})
+	p.closed = true
+	p.mu.Unlock()
+
+	for sub := range subscribers {
+		sub.close()
+	}
+
+	return nil
+}
+
+type subscriber struct {
+	out    chan models.PollResults
+	policy OverflowPolicy
+	done   chan struct{}
+
+	// Coalescing state. Only the flush goroutine writes to out, so results
+	// for a poll are never delivered out of order.
+	mu      sync.Mutex
+	pending map[string]models.PollResults
+	order   []string
+	wake    chan struct{}
+	flushed chan struct{}
+}
+
+func newSubscriber(bufferSize int, policy OverflowPolicy) *subscriber {
+	sub := &subscriber{
+		out:    make(chan models.PollResults, bufferSize),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+
+	if policy == PolicyCoalesce {
+		sub.pending = make(map[string]models.PollResults)
+		sub.wake = make(chan struct{}, 1)
+		sub.flushed = make(chan struct{})
+		go sub.flush()
+	}
+
+	return sub
+}
+
+func (s *subscriber) push(results models.PollResults) {
+	if s.policy == PolicyDrop {
+		select {
+		case s.out <- results:
+		default:
+		}
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.pending[results.PollID]; !ok {
+		s.order = append(s.order, results.PollID)
+	}
+	s.pending[results.PollID] = results
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) next() (models.PollResults, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) == 0 {
+		return models.PollResults{}, false
+	}
+
+	pollID := s.order[0]
+	s.order = s.order[1:]
+	results := s.pending[pollID]
+	delete(s.pending, pollID)
+
+	return results, true
+}
+
+func (s *subscriber) flush() {
+	defer close(s.flushed)
+
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		for {
+			results, ok := s.next()
+			if !ok {
+				break
+			}
+
+			select {
+			case s.out <- results:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	close(s.done)
+	if s.flushed != nil {
+		<-s.flushed
+	}
+	close(s.out)
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"poll/models"
+	"testing"
+	"time"
+)
+
+func newTestPublisher(t *testing.T, bufferSize int, policy OverflowPolicy) *Publisher {
+	t.Helper()
+
+	p, err := New(bufferSize, policy)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func subscribe(t *testing.T, p *Publisher) <-chan models.PollResults {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	results, err := p.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	return results
+}
+
+// publishAll publishes results with increasing counts for each poll, failing
+// the test if publishing ever waits for the subscriber.
+func publishAll(t *testing.T, p *Publisher, pollIDs []string, count int) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= count; i++ {
+			for _, pollID := range pollIDs {
+				if err := p.Publish(context.Background(), models.PollResults{PollID: pollID, Votes: map[string]int{"a": i}}); err != nil {
+					t.Errorf("failed to publish: %v", err)
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a subscriber that does not read")
+	}
+}
+
+func TestNewUnknownPolicy(t *testing.T) {
+	if _, err := New(1, "block"); err == nil {
+		t.Fatal("expected an error for an unknown overflow policy")
+	}
+}
+
+func TestPublisherDrop(t *testing.T) {
+	p := newTestPublisher(t, 2, PolicyDrop)
+	results := subscribe(t, p)
+
+	publishAll(t, p, []string{"p1"}, 5)
+
+	// The buffer keeps the first results, the rest are dropped.
+	if len(results) != 2 {
+		t.Fatalf("got %d buffered results, want 2", len(results))
+	}
+	for want := 1; want <= 2; want++ {
+		if got := (<-results).Votes["a"]; got != want {
+			t.Fatalf("got results with %d votes, want %d", got, want)
+		}
+	}
+
+	publishAll(t, p, []string{"p1"}, 1)
+	if got := (<-results).Votes["a"]; got != 1 {
+		t.Fatalf("got results with %d votes after catching up, want 1", got)
+	}
+}
+
+func TestPublisherCoalesce(t *testing.T) {
+	const bufferSize, count = 1, 100
+	p := newTestPublisher(t, bufferSize, PolicyCoalesce)
+	results := subscribe(t, p)
+
+	pollIDs := []string{"p1", "p2"}
+	publishAll(t, p, pollIDs, count)
+
+	latest := make(map[string]int)
+	received := 0
+	for latest["p1"] != count || latest["p2"] != count {
+		select {
+		case r := <-results:
+			received++
+			if got := r.Votes["a"]; got <= latest[r.PollID] {
+				t.Fatalf("got results with %d votes for %s after %d", got, r.PollID, latest[r.PollID])
+			}
+			latest[r.PollID] = r.Votes["a"]
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the latest results, got %v", latest)
+		}
+	}
+
+	// Besides the buffered and the one being handed over, a subscriber that
+	// falls behind holds at most one pending result per poll.
+	if limit := bufferSize + 1 + len(pollIDs); received > limit {
+		t.Fatalf("received %d results, want at most %d", received, limit)
+	}
+}
+
+func TestPublisherClose(t *testing.T) {
+	p := newTestPublisher(t, 1, PolicyCoalesce)
+	results := subscribe(t, p)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("failed to close publisher: %v", err)
+	}
+	if _, ok := <-results; ok {
+		t.Fatal("results channel still open after Close")
+	}
+	if err := p.Publish(context.Background(), models.PollResults{PollID: "p1"}); err == nil {
+		t.Fatal("expected an error publishing after Close")
+	}
+	if _, err := p.Subscribe(context.Background()); err == nil {
+		t.Fatal("expected an error subscribing after Close")
+	}
+}
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"poll/configs"
+	"poll/models"
+	"sync"
+	"time"
+)
+
+// publishTimeout bounds how long publishing a single message may take.
+const publishTimeout = 5 * time.Second
+
+// Publisher broadcasts results over a Redis Pub/Sub channel, so every
+// replica of the app receives results of votes cast on any other replica.
+// Results are queued and published in the background, so a slow Redis
+// server does not hold up voting; results that do not fit into the queue
+// are dropped.
+type Publisher struct {
+	client     *redis.Client
+	channel    string
+	bufferSize int
+
+	mu      sync.RWMutex
+	queue   chan []byte
+	closed  bool
+	drained chan struct{}
+}
+
+func New(ctx context.Context, cfg configs.RedisConfig, channel string, bufferSize int) (*Publisher, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		Username: cfg.Username,
+		DB:       cfg.DB,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("basic connection failure: %v", err)
+	}
+
+	p := &Publisher{
+		client:     client,
+		channel:    channel,
+		bufferSize: bufferSize,
+		queue:      make(chan []byte, bufferSize),
+		drained:    make(chan struct{}),
+	}
+	go p.drain()
+	return p, nil
+}
+
+// Publish queues the results to be published. It fails without waiting if
+// the queue is full.
+func (p *Publisher) Publish(_ context.Context, results models.PollResults) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal poll results: %w", err)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return fmt.Errorf("publisher is closed")
+	}
+
+	select {
+	case p.queue <- data:
+		return nil
+	default:
+		return fmt.Errorf("results queue is full, dropped results of poll %s", results.PollID)
+	}
+}
+
+// drain publishes queued results until the queue is closed.
+func (p *Publisher) drain() {
+	defer close(p.drained)
+
+	for data := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		if err := p.client.Publish(ctx, p.channel, data).Err(); err != nil {
+			log.Printf("failed to publish poll results: %v", err)
+		}
+		cancel()
+	}
+}
+
+// Subscribe returns a channel of results published by any replica from now
+// on. The channel is closed when ctx is done or the publisher is closed.
+func (p *Publisher) Subscribe(ctx context.Context) (<-chan models.PollResults, error) {
+	pubsub := p.client.Subscribe(ctx, p.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", p.channel, err)
+	}
+
+	out := make(chan models.PollResults, p.bufferSize)
+	messages := pubsub.Channel(redis.WithChannelSize(p.bufferSize))
+
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var results models.PollResults
+				if err := json.Unmarshal([]byte(msg.Payload), &results); err != nil {
+					log.Printf("error unmarshaling poll results: %v", err)
+					continue
+				}
+
+				select {
+				case out <- results:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Close publishes the results still queued and closes the connection.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	<-p.drained
+	return p.client.Close()
+}
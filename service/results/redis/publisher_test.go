@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"poll/configs"
+	"poll/models"
+	"testing"
+	"time"
+)
+
+func newTestPublisher(t *testing.T, addr string) *Publisher {
+	t.Helper()
+
+	p, err := New(context.Background(), configs.RedisConfig{Addr: addr}, "results", 16)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	return p
+}
+
+func receive(t *testing.T, results <-chan models.PollResults) models.PollResults {
+	t.Helper()
+
+	select {
+	case r, ok := <-results:
+		if !ok {
+			t.Fatal("results channel closed")
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for results")
+	}
+	return models.PollResults{}
+}
+
+func TestPublisher(t *testing.T) {
+	m := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The subscriber stands for another replica.
+	replica := newTestPublisher(t, m.Addr())
+	t.Cleanup(func() { replica.Close() })
+	results, err := replica.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	p := newTestPublisher(t, m.Addr())
+	for _, pollID := range []string{"p1", "p2", "p3"} {
+		if err := p.Publish(ctx, models.PollResults{PollID: pollID, Votes: map[string]int{"a": 1}}); err != nil {
+			t.Fatalf("failed to publish: %v", err)
+		}
+	}
+	// Closing publishes what is still queued.
+	if err := p.Close(); err != nil {
+		t.Fatalf("failed to close publisher: %v", err)
+	}
+
+	for _, want := range []string{"p1", "p2", "p3"} {
+		if got := receive(t, results); got.PollID != want || got.Votes["a"] != 1 {
+			t.Fatalf("got results %+v, want those of %s", got, want)
+		}
+	}
+
+	if err := p.Publish(ctx, models.PollResults{PollID: "p4"}); err == nil {
+		t.Error("publishing after Close succeeded")
+	}
+}
+
+func TestPublishDoesNotBlock(t *testing.T) {
+	// Nothing drains the queue, as if Redis were stuck.
+	p := &Publisher{queue: make(chan []byte, 1)}
+
+	if err := p.Publish(context.Background(), models.PollResults{PollID: "p1"}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if err := p.Publish(context.Background(), models.PollResults{PollID: "p2"}); err == nil {
+		t.Fatal("publishing to a full queue succeeded")
+	}
+}
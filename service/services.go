@@ -9,8 +9,38 @@ import (
 type PollService interface {
 	CreatePoll(ctx context.Context, poll models.Poll) (uuid.UUID, error)
 	GetPoll(ctx context.Context, pollID string) (*models.Poll, error)
-	ListPolls(ctx context.Context) ([]models.Poll, error)
-	DeletePoll(ctx context.Context, pollID string) error
-	UpdatePoll(ctx context.Context, pollID string, poll models.Poll) error
-	Vote(ctx context.Context, pollID string, option string) error
+	GetResults(ctx context.Context, pollID string) (*models.PollResults, error)
+	ListPolls(ctx context.Context, query models.PollQuery) (*models.PollPage, error)
+	// DeletePoll and UpdatePoll only succeed while the poll is at the given
+	// version, poll.Version for updates, unless that is 0.
+	DeletePoll(ctx context.Context, pollID string, version int64) error
+	UpdatePoll(ctx context.Context, pollID string, poll models.Poll) (*models.Poll, error)
+	ChangeStatus(ctx context.Context, pollID string, status models.PollStatus) (*models.Poll, error)
+	Vote(ctx context.Context, pollID string, vote models.Vote) error
+	ListVoteEvents(ctx context.Context, pollID string) ([]models.VoteEvent, error)
+	ReplayVotes(ctx context.Context, pollID string, apply bool) (*models.VoteReplay, error)
+	// ExportResults passes the results of every poll matching the query to
+	// fn, with their vote events if events is set and the caller may see
+	// them, until fn fails.
+	ExportResults(ctx context.Context, query models.PollQuery, events bool, fn func(models.PollResults, []models.VoteEvent) error) error
+	// ImportPolls creates the polls like CreatePoll, in one transaction,
+	// reporting the outcome for each. With atomic set, no poll is created
+	// unless all are valid; with dryRun set, none is.
+	ImportPolls(ctx context.Context, polls []models.Poll, atomic, dryRun bool) ([]ImportResult, error)
+}
+
+// ImportResult is the outcome of importing one poll: the ID it was created
+// under, or why it was not. Valid polls left out of an atomic import that
+// failed, or of a dry run, have neither.
+type ImportResult struct {
+	PollID uuid.UUID
+	Err    error
+}
+
+// ResultsPublisher fans poll results out to live subscribers, such as the
+// WebSocket server. Publish must not block on slow subscribers.
+type ResultsPublisher interface {
+	Publish(ctx context.Context, results models.PollResults) error
+	Subscribe(ctx context.Context) (<-chan models.PollResults, error)
+	Close() error
 }